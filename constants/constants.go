@@ -1,6 +1,14 @@
 package constants
 
+import "time"
+
 const CONFIG_DIR_NAME = "Executrix"
 const PIPELINE_DIR_NAME = "pipelines"
 const SERVER_CONFIG_FILE = "server.json"
 const GLOBAL_CONFIG_FILE = "globalconfig.json"
+const RUNS_DIR_NAME = "runs"
+const SECRETS_FILE = "secrets.json"
+const SECRET_ENV_PREFIX = "EXECUTRIX_SECRET_"
+
+// the pipeline dir is checked for changed files at this interval
+const PIPELINE_POLL_INTERVAL = 2 * time.Second
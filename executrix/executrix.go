@@ -1,62 +1,204 @@
 package executrix
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"log/slog"
-	"os/exec"
+	"maps"
+	"slices"
+	"sync"
+	"time"
 
 	"executrix/data"
+	"executrix/history"
+	"executrix/output"
 	"executrix/pipeline"
+	"executrix/server/config"
+	"executrix/step"
 )
 
 type Execution struct {
+	id         string
+	trigger    string
 	pipeline   *pipeline.Pipeline
 	stepInfo   []data.StepInfo
-	outputs    map[string]*string
-	currentCmd *exec.Cmd
+	params     map[string]string // values of all pipeline parameters
+	outputDir  string
+	secrets    config.Secrets
+	plan       []string
+	mu         sync.Mutex
+	outputs    map[string]*output.Log
+	attempts   map[string]int               // of the steps started so far
+	stepVars   map[string]map[string]string // output variables published per step
+	startedAt  time.Time
+	finishedAt time.Time
 	finished   bool
 	aborted    bool
+	cancelled  bool          // removed from the queue before it was started
+	abort      chan struct{} // closed when the execution is killed
 }
 
-func NewExecution(p *pipeline.Pipeline, stepInfo []data.StepInfo) (*Execution, error) {
+// NewExecution prepares the execution of the checked steps and their
+// dependencies with the given parameter values, using the defaults for
+// missing ones. trigger describes who or what requested the execution.
+func NewExecution(p *pipeline.Pipeline, stepInfo []data.StepInfo, params map[string]string, trigger string) (*Execution, error) {
 	if p == nil {
 		return nil, errors.New("pipeline must not be nil")
 	}
 
+	var checked []string
+	for _, info := range stepInfo {
+		if info.Checked {
+			checked = append(checked, info.StepName)
+		}
+	}
+
+	plan, err := p.ResolveSteps(checked)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := p.ResolveParameters(params)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newRunID()
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Resolved execution plan", "pipeline", p.Name, "run", id, "plan", plan)
+
 	return &Execution{
-		pipeline:   p,
-		stepInfo:   stepInfo,
-		outputs:    make(map[string]*string),
-		currentCmd: nil,
-		finished:   false,
-		aborted:    false,
+		id:       id,
+		trigger:  trigger,
+		pipeline: p,
+		stepInfo: stepInfo,
+		params:   resolved,
+		plan:     plan,
+		outputs:  make(map[string]*output.Log),
+		attempts: make(map[string]int),
+		stepVars: make(map[string]map[string]string),
+		abort:    make(chan struct{}),
+		finished: false,
+		aborted:  false,
 	}, nil
 }
 
+// newRunID creates a unique run ID. IDs sort in the order runs were created.
+func newRunID() (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	return fmt.Sprintf("%s-%03d-%s", now.Format("20060102-150405"), now.Nanosecond()/int(time.Millisecond), hex.EncodeToString(suffix)), nil
+}
+
+func (e *Execution) ID() string {
+	return e.id
+}
+
 func (e *Execution) PipelineName() string {
 	return e.pipeline.Name
 }
 
+// Plan returns the names of all steps to be executed, including the
+// dependencies of the checked steps, in dependency order.
+func (e *Execution) Plan() []string {
+	return e.plan
+}
+
+// Parameters returns the values of the pipeline parameters for this run.
+func (e *Execution) Parameters() map[string]string {
+	return e.params
+}
+
+// SetOutputDir sets the directory the results of the run are kept in, which
+// is passed on to the steps.
+func (e *Execution) SetOutputDir(dir string) {
+	e.outputDir = dir
+}
+
+// SetSecrets sets the secrets available to the steps. They are masked in the
+// output of the steps and in the record of the run.
+func (e *Execution) SetSecrets(secrets config.Secrets) {
+	e.secrets = secrets
+}
+
 func (e *Execution) SetFinished() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	e.finished = true
+	e.finishedAt = time.Now()
 }
 
-func (e Execution) IsFinished() bool {
+// Cancel marks an execution which has not been started as finished without
+// running any of its steps.
+func (e *Execution) Cancel() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.cancelled = true
+	e.finished = true
+	e.finishedAt = time.Now()
+}
+
+func (e *Execution) IsStarted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return !e.startedAt.IsZero()
+}
+
+func (e *Execution) IsFinished() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
 	return e.finished
 }
 
-func (e Execution) StepOutput(step string) (string, error) {
-	output, ok := e.outputs[step]
+func (e *Execution) StepOutput(step string) (*output.Log, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	out, ok := e.outputs[step]
 	if !ok {
-		return "", errors.New("step not found")
+		return nil, errors.New("step not found")
 	}
 
-	return *output, nil
+	return out, nil
+}
+
+// StepStates returns the current states of all steps of the pipeline,
+// together with the attempt of the steps started so far.
+func (e *Execution) StepStates() []pipeline.StateInfo {
+	states := e.pipeline.GetStepStates()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i := range states {
+		states[i].Attempt = e.attempts[states[i].Step]
+	}
+
+	return states
 }
 
 func (e *Execution) Kill() error {
 	slog.Info("Killing pipeline")
-	e.aborted = true
+
+	e.mu.Lock()
+	if !e.aborted {
+		e.aborted = true
+		close(e.abort)
+	}
+	e.mu.Unlock()
 
 	for _, step := range e.pipeline.Steps {
 		if err := step.Kill(); err != nil {
@@ -68,30 +210,289 @@ func (e *Execution) Kill() error {
 	return nil
 }
 
+func (e *Execution) isAborted() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.aborted
+}
+
+// Execute runs all steps of the plan. A step is started as soon as all of its
+// dependencies have succeeded, with at most MaxParallel steps of the pipeline
+// running at the same time. Steps depending on a failed step are blocked, and
+// with the FailFast policy no more steps are started after a failure.
 func (e *Execution) Execute() {
-	slog.Info("Starting pipeline")
+	e.mu.Lock()
+	e.startedAt = time.Now()
+	e.mu.Unlock()
+
+	slog.Info("Starting pipeline", "run", e.id, "maxParallel", e.pipeline.MaxParallel, "failurePolicy", e.pipeline.FailurePolicy)
 
-	for _, step := range e.stepInfo {
-		if e.aborted {
+	var pending []step.IStep
+	for _, name := range e.plan {
+		pending = append(pending, e.pipeline.FindStep(name))
+	}
+
+	done := make(chan step.IStep)
+	running := 0
+	stopped := false
+
+	for {
+		for !stopped && !e.isAborted() && running < e.pipeline.MaxParallel {
+			idx := slices.IndexFunc(pending, e.isReady)
+			if idx < 0 {
+				break
+			}
+
+			s := pending[idx]
+			pending = slices.Delete(pending, idx, idx+1)
+
+			out := output.NewLog()
+			out.SetMask(e.secrets.Mask)
+			e.mu.Lock()
+			e.outputs[s.ShowAs()] = out
+			e.mu.Unlock()
+
+			running++
+			go func() {
+				e.run(s, out)
+				done <- s
+			}()
+		}
+
+		if running == 0 {
 			break
 		}
 
-		if !step.Checked {
-			slog.Info("Skipping unchecked step", "step", step.StepName)
-			continue
+		s := <-done
+		running--
+		slog.Info("Step finished", "step", s.ShowAs(), "state", s.GetState())
+
+		if hasFailed(s) {
+			if e.pipeline.FailurePolicy == pipeline.FailFast && !stopped {
+				slog.Warn("Stopping pipeline after failed step", "step", s.ShowAs())
+				stopped = true
+			}
+
+			pending = e.blockDependents(pending)
+		}
+	}
+
+	for _, s := range e.blockDependents(pending) {
+		slog.Info("Skipping step", "step", s.ShowAs())
+		s.SetState(step.Skipped)
+	}
+
+	slog.Info("Pipeline finished")
+}
+
+// run executes s and retries it while it fails, as configured for the step.
+// A step succeeding after a retry ends in state Semi.
+func (e *Execution) run(s step.IStep, out *output.Log) {
+	retry := s.RetryPolicy()
+	run := step.Run{
+		Limits:  e.pipeline.Limits(s),
+		Vars:    e.vars(),
+		Params:  e.params,
+		Publish: func(name, value string) { e.publish(s.ShowAs(), name, value) },
+		Abort:   e.abort,
+		Env: map[string]string{
+			"EXECUTRIX_RUN_ID":     e.id,
+			"EXECUTRIX_PIPELINE":   e.pipeline.Name,
+			"EXECUTRIX_STEP":       s.ShowAs(),
+			"EXECUTRIX_OUTPUT_DIR": e.outputDir,
+		},
+	}
+
+	for attempt := 1; ; attempt++ {
+		e.mu.Lock()
+		e.attempts[s.ShowAs()] = attempt
+		e.mu.Unlock()
+
+		if retry.Retries > 0 {
+			out.StartAttempt(attempt)
+			out.Append(output.System, fmt.Sprintf("Attempt %d of %d", attempt, retry.Retries+1))
 		}
 
-		pStep := e.pipeline.FindStep(step.StepName)
-		if pStep == nil {
-			slog.Error("Could not find Pipeline Step!")
-			// todo error handling
+		s.Execute(out, run)
+
+		if s.GetState() != step.Failed || attempt > retry.Retries || e.isAborted() {
+			if s.GetState() == step.Success && attempt > 1 {
+				s.SetState(step.Semi)
+			}
+			return
 		}
 
-		s := ""
-		e.outputs[step.StepName] = &s
+		delay := retry.DelayAfter(attempt)
+		slog.Warn("Retrying failed step", "step", s.ShowAs(), "attempt", attempt, "delay", delay)
+		out.Append(output.System, "")
+		out.Append(output.System, "Retrying in "+delay.String())
+		out.Append(output.System, "")
 
-		pStep.Execute(e.outputs[step.StepName])
+		// the step is not finished before the last attempt
+		s.SetState(step.Running)
+
+		select {
+		case <-time.After(delay):
+		case <-e.abort:
+		}
+
+		if e.isAborted() {
+			s.SetState(step.Failed)
+			return
+		}
 	}
+}
 
-	slog.Info("Pipeline finished")
+// vars returns the parameters of the run together with the output variables
+// published by the steps so far and the secrets, which cannot be overridden.
+func (e *Execution) vars() map[string]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	vars := maps.Clone(e.params)
+	if vars == nil {
+		vars = make(map[string]string)
+	}
+	if _, ok := vars[pipeline.DateVar]; !ok {
+		vars[pipeline.DateVar] = e.startedAt.Format(time.DateOnly)
+	}
+	for s, published := range e.stepVars {
+		for name, value := range published {
+			vars[step.OutputVar(s, name)] = value
+		}
+	}
+
+	for name, value := range e.secrets.GetVars() {
+		vars[name] = value
+	}
+
+	return vars
+}
+
+func (e *Execution) publish(s string, name string, value string) {
+	slog.Debug("Step published output variable", "step", s, "name", name)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.stepVars[s] == nil {
+		e.stepVars[s] = make(map[string]string)
+	}
+	e.stepVars[s][name] = value
+}
+
+// Record returns the history record of the execution, including the
+// current states and outputs of all planned steps.
+func (e *Execution) Record() history.Run {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	run := history.Run{
+		ID:         e.id,
+		Pipeline:   e.pipeline.Name,
+		Trigger:    e.trigger,
+		Status:     history.Running,
+		StartedAt:  e.startedAt,
+		FinishedAt: e.finishedAt,
+		Plan:       e.plan,
+		Parameters: e.masked(e.params),
+	}
+
+	for _, info := range e.stepInfo {
+		if info.Checked {
+			run.Selected = append(run.Selected, info.StepName)
+		}
+	}
+
+	started := !e.startedAt.IsZero()
+
+	failed := false
+	for _, name := range e.plan {
+		result := history.StepResult{Step: name}
+		// the steps of the pipeline hold the states of the current execution
+		if s := e.pipeline.FindStep(name); s != nil && started {
+			result.State = s.GetState()
+			failed = failed || hasFailed(s) || result.State == step.Blocked
+		}
+		result.Attempts = e.attempts[name]
+		result.Outputs = e.masked(e.stepVars[name])
+		result.Output = e.outputs[name]
+
+		run.Steps = append(run.Steps, result)
+	}
+
+	switch {
+	case e.cancelled:
+		run.Status = history.Cancelled
+	case !started:
+		run.Status = history.Queued
+	case !e.finished:
+		run.Status = history.Running
+	case e.aborted:
+		run.Status = history.Killed
+	case failed:
+		run.Status = history.Failed
+	default:
+		run.Status = history.Success
+	}
+
+	return run
+}
+
+// masked returns a copy of vars with all secrets masked.
+func (e *Execution) masked(vars map[string]string) map[string]string {
+	result := maps.Clone(vars)
+	for name, value := range result {
+		result[name] = e.secrets.Mask(value)
+	}
+
+	return result
+}
+
+// hasFailed reports whether s failed in a way that affects the rest of the
+// pipeline.
+func hasFailed(s step.IStep) bool {
+	return s.GetState() == step.Failed && !s.ContinuesOnError()
+}
+
+// isReady reports whether all dependencies of s have succeeded.
+func (e *Execution) isReady(s step.IStep) bool {
+	for _, dep := range s.Dependencies() {
+		d := e.pipeline.FindStep(dep)
+		if d == nil {
+			return false
+		}
+
+		switch d.GetState() {
+		case step.Success, step.Semi:
+			continue
+		case step.Failed:
+			if d.ContinuesOnError() {
+				continue
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// blockDependents sets all pending steps with a failed or blocked dependency
+// to Blocked and returns the remaining ones. As pending steps are ordered by
+// dependency, blocking spreads to indirect dependents in a single pass.
+func (e *Execution) blockDependents(pending []step.IStep) []step.IStep {
+	return slices.DeleteFunc(pending, func(s step.IStep) bool {
+		for _, dep := range s.Dependencies() {
+			d := e.pipeline.FindStep(dep)
+			if d != nil && (hasFailed(d) || d.GetState() == step.Blocked) {
+				slog.Info("Blocking step after failed dependency", "step", s.ShowAs(), "dependency", dep)
+				s.SetState(step.Blocked)
+				return true
+			}
+		}
+
+		return false
+	})
 }
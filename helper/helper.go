@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func Exists(path string) (bool, error) {
@@ -82,28 +83,77 @@ func ReadFile(path string) ([]byte, error) {
 	return bytes, nil
 }
 
-func CleanUpString(s string) string {
-	result := s
-	result = strings.ReplaceAll(result, `'`, `"`)
-	result = strings.ReplaceAll(result, `\`, `\\`)
-	return result
+// ReplaceAll substitutes the value of every var in m for $(name) in s.
+// Escaped references like $$(name) are kept as they are, Unescape turns them
+// into $(name) once all vars have been substituted.
+func ReplaceAll(s string, m map[string]string) string {
+	return ReplaceFunc(s, func(name string) (string, bool) {
+		value, ok := m[name]
+		return value, ok
+	})
 }
 
-func ForJSON(s string) string {
-	result := s
-	result = strings.ReplaceAll(result, `"`, `\"`)
-	result = strings.ReplaceAll(result, "\t", "    ")
-	return result
-}
+// ReplaceFunc substitutes the value returned by lookup for every $(name) in
+// s, references for which lookup returns false are kept. Like ReplaceAll it
+// makes a single pass and skips escaped references.
+func ReplaceFunc(s string, lookup func(name string) (string, bool)) string {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "$(")
+		end := strings.IndexByte(s[max(i, 0):], ')')
+		if i < 0 || end < 0 {
+			break
+		}
 
-func ReplaceAll(s string, m map[string]string) string {
-	result := s
-	for key := range m {
-		result = strings.ReplaceAll(result, fmt.Sprintf("$(%s)", key), m[key])
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+
+		value, ok := lookup(s[i+2 : i+end])
+		if !ok {
+			b.WriteString(s[:i+2])
+			s = s[i+2:]
+			continue
+		}
+
+		// the value is not searched for further vars
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
 	}
-	return result
+	b.WriteString(s)
+
+	return b.String()
 }
 
-func AppendLine(s *string, line string) {
-	*s += line + "\\n"
+// Unescape turns every $$( in s into $(, so that scripts can use the
+// $(...) syntax themselves.
+func Unescape(s string) string {
+	return strings.ReplaceAll(s, "$$(", "$(")
+}
+
+// ParseDuration reads a duration from a JSON value, which is either a string
+// like "1h30m" or a number of seconds.
+func ParseDuration(val interface{}) (time.Duration, error) {
+	var d time.Duration
+	switch v := val.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return 0, err
+		}
+		d = parsed
+	case float64:
+		d = time.Duration(v * float64(time.Second))
+	default:
+		return 0, fmt.Errorf("unexpected type %T for duration", val)
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("duration %s is negative", d)
+	}
+
+	return d, nil
 }
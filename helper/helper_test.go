@@ -0,0 +1,115 @@
+package helper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplaceAll(t *testing.T) {
+	vars := map[string]string{
+		"name":  "world",
+		"empty": "",
+		"ref":   "$(name)",
+	}
+
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", ""},
+		{"no vars", "no vars"},
+		{"hello $(name)", "hello world"},
+		{"$(name)$(name)", "worldworld"},
+		{"[$(empty)]", "[]"},
+		{"$(unknown) $(name)", "$(unknown) world"},
+		{"escaped $$(name)", "escaped $$(name)"},
+		{"$$(name) $(name)", "$$(name) world"},
+		{"not rescanned $(ref)", "not rescanned $(name)"},
+		{"unclosed $(name", "unclosed $(name"},
+		{"$(name) unclosed $(", "world unclosed $("},
+		{"with spaces $(date +%F)", "with spaces $(date +%F)"},
+		{"$(a:-b)", "$(a:-b)"},
+		{"$name (name)", "$name (name)"},
+	}
+
+	for _, test := range tests {
+		if got := ReplaceAll(test.s, vars); got != test.want {
+			t.Errorf("ReplaceAll(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestReplaceFunc(t *testing.T) {
+	var refs []string
+	lookup := func(ref string) (string, bool) {
+		refs = append(refs, ref)
+		return "<" + ref + ">", ref != "kept"
+	}
+
+	got := ReplaceFunc("$(a) $$(b) $(kept) $(c:-fallback) $(env:HOME)", lookup)
+	if want := "<a> $$(b) $(kept) <c:-fallback> <env:HOME>"; got != want {
+		t.Errorf("ReplaceFunc = %q, want %q", got, want)
+	}
+
+	// escaped references are not looked up
+	want := []string{"a", "kept", "c:-fallback", "env:HOME"}
+	if len(refs) != len(want) {
+		t.Fatalf("looked up %q, want %q", refs, want)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("looked up %q, want %q", refs, want)
+			break
+		}
+	}
+}
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"", ""},
+		{"$$(date)", "$(date)"},
+		{"$(date)", "$(date)"},
+		{"$$$(date)", "$$(date)"},
+		{"$$ and $$(a) $$(b)", "$$ and $(a) $(b)"},
+	}
+
+	for _, test := range tests {
+		if got := Unescape(test.s); got != test.want {
+			t.Errorf("Unescape(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		val  any
+		want time.Duration
+		err  bool
+	}{
+		{"1h30m", 90 * time.Minute, false},
+		{"0s", 0, false},
+		{float64(90), 90 * time.Second, false},
+		{1.5, 1500 * time.Millisecond, false},
+		{"90", 0, true},
+		{"-1s", 0, true},
+		{float64(-1), 0, true},
+		{true, 0, true},
+		{nil, 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDuration(test.val)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseDuration(%v) = %s, want error", test.val, got)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("ParseDuration(%v) = %s, %v, want %s", test.val, got, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package helper
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// FieldError is an error in the value of a field of a JSON file.
+type FieldError struct {
+	Field string // path of the field, e.g. Steps[2].Arguments
+	Err   error
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Err.Error()
+}
+
+func (e FieldError) Unwrap() error {
+	return e.Err
+}
+
+// InField attributes err to the field. Errors of nested fields keep their
+// path below it, e.g. Schedule.Steps, and every error joined in err is
+// attributed separately.
+func InField(field string, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, InField(field, e))
+		}
+		return errors.Join(errs...)
+	}
+
+	var nested FieldError
+	if errors.As(err, &nested) {
+		if !strings.HasPrefix(nested.Field, "[") {
+			field += "."
+		}
+		return FieldError{Field: field + nested.Field, Err: nested.Err}
+	}
+
+	return FieldError{Field: field, Err: err}
+}
+
+// DecodeStrict decodes the JSON value in data into v. Unlike json.Unmarshal,
+// it rejects unknown fields and continues after a problem, so that all
+// problems are returned at once, each as a FieldError.
+func DecodeStrict(data []byte, v any) error {
+	// syntax errors, e.g. content after the value, keep their offset
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	return decodeValue(raw, reflect.ValueOf(v).Elem())
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func decodeValue(raw json.RawMessage, v reflect.Value) error {
+	t := v.Type()
+	if bytes.Equal(bytes.TrimSpace(raw), []byte("null")) || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return unmarshalValue(raw, v)
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		elem := reflect.New(t.Elem())
+		if err := decodeValue(raw, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case reflect.Struct:
+		return decodeStruct(raw, v)
+	case reflect.Slice:
+		var elems []json.RawMessage
+		if err := unmarshalValue(raw, reflect.ValueOf(&elems).Elem()); err != nil {
+			return typeError(err, t)
+		}
+
+		list := reflect.MakeSlice(t, len(elems), len(elems))
+		var errs []error
+		for i, elem := range elems {
+			if err := decodeValue(elem, list.Index(i)); err != nil {
+				errs = append(errs, InField(fmt.Sprintf("[%d]", i), err))
+			}
+		}
+		v.Set(list)
+		return errors.Join(errs...)
+	case reflect.Map:
+		var entries map[string]json.RawMessage
+		if err := unmarshalValue(raw, reflect.ValueOf(&entries).Elem()); err != nil {
+			return typeError(err, t)
+		}
+
+		m := reflect.MakeMapWithSize(t, len(entries))
+		var errs []error
+		for _, key := range sortedKeys(entries) {
+			elem := reflect.New(t.Elem()).Elem()
+			if err := decodeValue(entries[key], elem); err != nil {
+				errs = append(errs, InField(key, err))
+				continue
+			}
+			m.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), elem)
+		}
+		v.Set(m)
+		return errors.Join(errs...)
+	default:
+		return unmarshalValue(raw, v)
+	}
+}
+
+// decodeStruct decodes every field on its own, matching the names like
+// json.Unmarshal does.
+func decodeStruct(raw json.RawMessage, v reflect.Value) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return typeError(err, v.Type())
+	}
+
+	var errs []error
+	for _, key := range sortedKeys(fields) {
+		field, ok := structField(v, key)
+		if !ok {
+			errs = append(errs, InField(key, errors.New("unknown field")))
+			continue
+		}
+
+		if err := decodeValue(fields[key], field); err != nil {
+			errs = append(errs, InField(key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// structField returns the field of v, including fields of embedded structs,
+// which is decoded from the JSON key. An exact match is preferred over a
+// case-insensitive one.
+func structField(v reflect.Value, key string) (reflect.Value, bool) {
+	var folded reflect.Value
+	found := false
+
+	for _, f := range reflect.VisibleFields(v.Type()) {
+		if !f.IsExported() || f.Anonymous && f.Type.Kind() == reflect.Struct {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch {
+		case name == "-":
+			continue
+		case name == "":
+			name = f.Name
+		}
+
+		if name == key {
+			return v.FieldByIndex(f.Index), true
+		}
+		if !found && strings.EqualFold(name, key) {
+			folded, found = v.FieldByIndex(f.Index), true
+		}
+	}
+
+	return folded, found
+}
+
+func unmarshalValue(raw json.RawMessage, v reflect.Value) error {
+	if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+		return typeError(err, v.Type())
+	}
+
+	return nil
+}
+
+// typeError describes a value of the wrong type without the Go types of
+// the json package.
+func typeError(err error, t reflect.Type) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return fmt.Errorf("has to be %s, not %s", jsonType(t), typeErr.Value)
+	}
+
+	return err
+}
+
+func sortedKeys(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// jsonType describes the JSON values decoded into t.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	case reflect.Pointer:
+		return jsonType(t.Elem())
+	default:
+		return t.String()
+	}
+}
@@ -0,0 +1,138 @@
+package helper
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testStep struct {
+	Name    string
+	Retries int
+	Args    []string
+	Env     map[string]string
+	Inherit *bool
+}
+
+type testFile struct {
+	Name     string
+	Timeout  any
+	Steps    []testStep
+	Schedule *testSchedule
+	Level    string
+	LEVEL    string // only matched exactly
+	Renamed  string `json:"other"`
+	Ignored  string `json:"-"`
+	internal string
+}
+
+type testSchedule struct {
+	Cron []string
+}
+
+func TestDecodeStrict(t *testing.T) {
+	inherit := false
+
+	tests := []struct {
+		name string
+		json string
+		want testFile
+	}{
+		{"empty", `{}`, testFile{}},
+		{"exact names", `{"Name": "a", "Steps": [{"Name": "b", "Retries": 2}]}`,
+			testFile{Name: "a", Steps: []testStep{{Name: "b", Retries: 2}}}},
+		{"case-insensitive names", `{"name": "a", "steps": [{"ARGS": ["x"]}]}`,
+			testFile{Name: "a", Steps: []testStep{{Args: []string{"x"}}}}},
+		{"exact match preferred", `{"LEVEL": "exact"}`, testFile{LEVEL: "exact"}},
+		{"first case-insensitive match", `{"level": "folded"}`, testFile{Level: "folded"}},
+		{"tag", `{"other": "x"}`, testFile{Renamed: "x"}},
+		{"any", `{"Timeout": 1.5}`, testFile{Timeout: 1.5}},
+		{"maps and pointers", `{"Steps": [{"Env": {"A": "1"}, "Inherit": false}], "Schedule": {"Cron": ["@daily"]}}`,
+			testFile{Steps: []testStep{{Env: map[string]string{"A": "1"}, Inherit: &inherit}}, Schedule: &testSchedule{Cron: []string{"@daily"}}}},
+		{"null", `{"Name": null, "Schedule": null}`, testFile{}},
+	}
+
+	for _, test := range tests {
+		var got testFile
+		if err := DecodeStrict([]byte(test.json), &got); err != nil {
+			t.Errorf("%s: DecodeStrict(%s): %v", test.name, test.json, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: DecodeStrict(%s) = %+v, want %+v", test.name, test.json, got, test.want)
+		}
+	}
+}
+
+func TestDecodeStrictErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+		errs []string
+	}{
+		{"unknown field", `{"Nmae": "a"}`, []string{"Nmae: unknown field"}},
+		{"ignored field", `{"Ignored": "a"}`, []string{"Ignored: unknown field"}},
+		{"unexported field", `{"internal": "a"}`, []string{"internal: unknown field"}},
+		{"renamed field", `{"Renamed": "a"}`, []string{"Renamed: unknown field"}},
+		{"nested unknown field", `{"Steps": [{"Name": "a"}, {"Nmae": "b"}]}`, []string{"Steps[1].Nmae: unknown field"}},
+		{"string", `{"Name": 1}`, []string{"Name: has to be a string, not number"}},
+		{"integer", `{"Steps": [{"Retries": "2"}]}`, []string{"Steps[0].Retries: has to be an integer, not string"}},
+		{"fraction", `{"Steps": [{"Retries": 1.5}]}`, []string{"Steps[0].Retries: has to be an integer, not number 1.5"}},
+		{"list", `{"Steps": {}}`, []string{"Steps: has to be a list, not object"}},
+		{"list element", `{"Steps": [{"Args": ["a", 2]}]}`, []string{"Steps[0].Args[1]: has to be a string, not number"}},
+		{"map value", `{"Steps": [{"Env": {"A": true}}]}`, []string{"Steps[0].Env.A: has to be a string, not bool"}},
+		{"pointer", `{"Schedule": {"Cron": "@daily"}}`, []string{"Schedule.Cron: has to be a list, not string"}},
+		{"object", `[]`, []string{"has to be an object, not array"}},
+		{"multiple errors", `{"Steps": [{"Name": 1, "Retries": "x"}, {"Foo": 1}], "Bar": 2, "Name": []}`, []string{
+			"Bar: unknown field",
+			"Name: has to be a string, not array",
+			"Steps[0].Name: has to be a string, not number",
+			"Steps[0].Retries: has to be an integer, not string",
+			"Steps[1].Foo: unknown field",
+		}},
+	}
+
+	for _, test := range tests {
+		var got testFile
+		err := DecodeStrict([]byte(test.json), &got)
+		if err == nil {
+			t.Errorf("%s: DecodeStrict(%s) succeeded, want errors %q", test.name, test.json, test.errs)
+			continue
+		}
+		if errs := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: DecodeStrict(%s) = %q, want %q", test.name, test.json, errs, test.errs)
+		}
+	}
+}
+
+func TestDecodeStrictFieldErrors(t *testing.T) {
+	var got testFile
+	err := DecodeStrict([]byte(`{"Steps": [{"Retries": "x"}]}`), &got)
+
+	var fieldErr FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Fatalf("DecodeStrict = %v, want a FieldError", err)
+	}
+	if want := "Steps[0].Retries"; fieldErr.Field != want {
+		t.Errorf("field of error = %q, want %q", fieldErr.Field, want)
+	}
+}
+
+func TestDecodeStrictSyntaxError(t *testing.T) {
+	tests := []string{``, `{"Name": "a"`, `{"Name": "a"} {}`, `{"Name": "a",}`}
+
+	for _, data := range tests {
+		var got testFile
+		err := DecodeStrict([]byte(data), &got)
+		if err == nil {
+			t.Errorf("DecodeStrict(%s) succeeded, want syntax error", data)
+			continue
+		}
+
+		var fieldErr FieldError
+		if errors.As(err, &fieldErr) {
+			t.Errorf("DecodeStrict(%s) = %v, want a syntax error without field", data, err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+//go:build !windows
+
+package helper
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// ProcessExitGroup keeps track of the process groups of all processes added
+// to it, so that they can be interrupted and killed together.
+//
+// On linux, each command is run by a supervisor, which kills the rest of the
+// process group of the command once it has exited, and the whole group if
+// the server dies, see Supervise. On other unix systems, processes left
+// running by the command and its children survive a crash of the server.
+type ProcessExitGroup struct {
+	*exitGroup
+}
+
+// supervisorKillSignal is sent to a supervisor when the server dies or the
+// step is killed.
+const supervisorKillSignal = unix.SIGHUP
+
+type exitGroup struct {
+	mu         sync.Mutex
+	pgids      []int
+	supervised bool
+}
+
+func NewProcessExitGroup() (ProcessExitGroup, error) {
+	return ProcessExitGroup{&exitGroup{}}, nil
+}
+
+// Prepare has to be called on cmd before it is started, Dispose has to be
+// called by the same goroutine. The process becomes the leader of a new
+// process group, so that its children can be signalled together with it.
+func (g ProcessExitGroup) Prepare(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.supervised = superviseCommand(cmd)
+}
+
+// Dispose releases the group once the processes have exited. It does not
+// signal them, as their process groups may already have been reused.
+func (g ProcessExitGroup) Dispose() error {
+	releaseThread()
+
+	return nil
+}
+
+func (g ProcessExitGroup) AddProcess(p *os.Process) error {
+	pgid, err := unix.Getpgid(p.Pid)
+	if err != nil {
+		return err
+	}
+
+	if pgid != p.Pid {
+		return errors.New("process is not leading its own process group")
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pgids = append(g.pgids, pgid)
+
+	return nil
+}
+
+// AwaitExit blocks until p has exited, without releasing its process ID. Its
+// process group can be signalled safely until p is waited for.
+func (g ProcessExitGroup) AwaitExit(p *os.Process) error {
+	return awaitExit(p)
+}
+
+// Interrupt asks the process group led by p to terminate by sending SIGTERM.
+// A supervisor passes it on to its command.
+func (g ProcessExitGroup) Interrupt(p *os.Process) error {
+	if err := unix.Kill(-p.Pid, unix.SIGTERM); err != nil && !errors.Is(err, unix.ESRCH) {
+		return err
+	}
+
+	return nil
+}
+
+// Terminate kills all processes of the group. Supervisors are asked to kill
+// the process group of their command and then exit.
+func (g ProcessExitGroup) Terminate() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	sig := unix.SIGKILL
+	if g.supervised {
+		sig = supervisorKillSignal
+	}
+
+	var errs []error
+	for _, pgid := range g.pgids {
+		// the group is gone if all of its processes have already exited
+		if err := unix.Kill(-pgid, sig); err != nil && !errors.Is(err, unix.ESRCH) {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
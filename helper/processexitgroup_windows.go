@@ -1,9 +1,13 @@
+//go:build windows
+
 package helper
 
 import (
 	"os"
+	"os/exec"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -52,3 +56,31 @@ func (g ProcessExitGroup) AddProcess(p *os.Process) error {
 		windows.Handle((*process)(unsafe.Pointer(p)).Handle),
 	)
 }
+
+// AwaitExit returns right away, the handle of a process keeps it from being
+// mistaken for another one.
+func (g ProcessExitGroup) AwaitExit(p *os.Process) error {
+	return nil
+}
+
+// Prepare has to be called on cmd before it is started. Processes are
+// assigned to the job object after starting, but they need their own console
+// process group to be interruptible.
+func (g ProcessExitGroup) Prepare(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_NEW_PROCESS_GROUP
+}
+
+// Interrupt asks the console process group led by p to terminate by sending
+// a CTRL+BREAK event.
+func (g ProcessExitGroup) Interrupt(p *os.Process) error {
+	return windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(p.Pid))
+}
+
+// Terminate kills all processes of the group.
+func (g ProcessExitGroup) Terminate() error {
+	return windows.TerminateJobObject(windows.Handle(g), 1)
+}
@@ -0,0 +1,144 @@
+//go:build linux
+
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// SuperviseCommand is the hidden command the server runs script steps with,
+// see Supervise.
+const SuperviseCommand = "supervise"
+
+// superviseCommand wraps cmd, so that it is run by a supervisor, see
+// Supervise, and reports whether it is. The supervisor is a copy of the
+// running server binary.
+func superviseCommand(cmd *exec.Cmd) bool {
+	// the lookup of the command failed, Start reports it
+	if cmd.Err != nil {
+		return false
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		slog.Warn("Running command without supervisor", "error", err)
+		return false
+	}
+
+	args := []string{self, SuperviseCommand, strconv.Itoa(os.Getpid()), cmd.Path}
+	cmd.Args = append(args, cmd.Args[1:]...)
+	cmd.Path = self
+	cmd.SysProcAttr.Pdeathsig = supervisorKillSignal
+
+	// Pdeathsig is sent when the thread which started the process exits, not
+	// the server. The goroutine keeps its thread until releaseThread, so that
+	// the runtime does not end the thread while the step is running.
+	runtime.LockOSThread()
+
+	return true
+}
+
+func releaseThread() {
+	runtime.UnlockOSThread()
+}
+
+// awaitExit waits for p to exit without reaping it, which keeps its process
+// ID and with it its process group from being reused.
+func awaitExit(p *os.Process) error {
+	var info unix.Siginfo
+	for {
+		err := unix.Waitid(unix.P_PID, p.Pid, &info, unix.WEXITED|unix.WNOWAIT, nil)
+		if !errors.Is(err, unix.EINTR) {
+			return err
+		}
+	}
+}
+
+// Supervise runs the command in args after the pid of the server and
+// returns its exit code. It is started as the only process of its process
+// group, the command leads a process group of its own.
+//
+// The supervisor passes SIGTERM and SIGINT on to the process group of the
+// command. On supervisorKillSignal, which it also receives when the server
+// dies, even if it is killed, it kills the group. Once the command has
+// exited, processes it left running in its group are killed as well.
+//
+// Processes which leave the group, e.g. daemons calling setsid, are not
+// covered, nor is killing the supervisor on its own.
+func Supervise(args []string) int {
+	if len(args) < 2 {
+		fmt.Fprintln(os.Stderr, "usage: executrix supervise <server pid> <command> [<arguments>]")
+		return 2
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, supervisorKillSignal, unix.SIGTERM, unix.SIGINT)
+
+	// the server may have died before the death signal was set up
+	if strconv.Itoa(os.Getppid()) != args[0] {
+		return 1
+	}
+
+	// handled signals are reset to their default for the command
+	cmd := exec.Command(args[1], args[2:]...)
+	cmd.Args[0] = args[1]
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 127
+	}
+
+	// the command is not reaped before its group has been killed
+	pgid := cmd.Process.Pid
+	exited := make(chan error, 1)
+	go func() { exited <- awaitExit(cmd.Process) }()
+
+	for {
+		select {
+		case sig := <-signals:
+			if sig == supervisorKillSignal {
+				unix.Kill(-pgid, unix.SIGKILL)
+			} else {
+				unix.Kill(-pgid, sig.(syscall.Signal))
+			}
+		case <-exited:
+			unix.Kill(-pgid, unix.SIGKILL)
+			return exitCode(cmd.Wait())
+		}
+	}
+}
+
+// exitCode passes on the exit of the supervised command. A command killed by
+// a signal kills the supervisor with the same signal.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if ok && status.Signaled() {
+		signal.Reset(status.Signal())
+		unix.Kill(os.Getpid(), status.Signal())
+		// the default action of the signal is to ignore it
+		return 128 + int(status.Signal())
+	}
+
+	return exitErr.ExitCode()
+}
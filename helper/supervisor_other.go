@@ -0,0 +1,34 @@
+//go:build !linux
+
+package helper
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// SuperviseCommand is the hidden command the server runs script steps with
+// on linux.
+const SuperviseCommand = "supervise"
+
+// Supervise is only used on linux. On windows, the job object of a
+// ProcessExitGroup is closed when the server dies, which kills all of its
+// processes.
+func Supervise(args []string) int {
+	fmt.Fprintln(os.Stderr, "supervise is only supported on linux")
+	return 2
+}
+
+// superviseCommand leaves cmd as it is, there is no supervisor.
+func superviseCommand(cmd *exec.Cmd) bool {
+	return false
+}
+
+func releaseThread() {}
+
+// awaitExit returns right away, processes are only waited for by reaping
+// them.
+func awaitExit(p *os.Process) error {
+	return nil
+}
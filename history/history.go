@@ -0,0 +1,234 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"executrix/helper"
+	"executrix/output"
+	"executrix/step"
+)
+
+var ErrRunNotFound = errors.New("run not found")
+
+// run IDs are generated by the executor and used as file names
+var runIDPattern = regexp.MustCompile(`^[0-9a-z-]+$`)
+
+// summarySuffix is appended to the ID of a run for the file of its summary.
+const summarySuffix = ".summary.json"
+
+type Status string
+
+const (
+	Queued  Status = "Queued"
+	Running Status = "Running"
+	Success Status = "Success"
+	Failed  Status = "Failed"
+	Killed  Status = "Killed"
+	// the run was removed from the queue before it was started
+	Cancelled Status = "Cancelled"
+)
+
+type StepResult struct {
+	Step     string            `json:"step"`
+	State    step.State        `json:"state"`
+	Attempts int               `json:"attempts,omitempty"`
+	Outputs  map[string]string `json:"outputs,omitempty"` // variables published for later steps
+	Output   *output.Log       `json:"output,omitempty"`
+}
+
+// Run is the record of a single pipeline execution.
+type Run struct {
+	ID         string            `json:"id"`
+	Pipeline   string            `json:"pipeline"`
+	Trigger    string            `json:"trigger"`
+	Status     Status            `json:"status"`
+	StartedAt  time.Time         `json:"startedAt"`
+	FinishedAt time.Time         `json:"finishedAt"`
+	Selected   []string          `json:"selected"`
+	Plan       []string          `json:"plan"`
+	Parameters map[string]string `json:"parameters,omitempty"`
+	Steps      []StepResult      `json:"steps"`
+}
+
+// Duration returns how long the run took, or zero if it has not finished.
+func (r Run) Duration() time.Duration {
+	if r.StartedAt.IsZero() || r.FinishedAt.IsZero() {
+		return 0
+	}
+
+	return r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond)
+}
+
+// Summary returns a copy of the run without the step outputs.
+func (r Run) Summary() Run {
+	r.Steps = slices.Clone(r.Steps)
+	for i := range r.Steps {
+		r.Steps[i].Output = nil
+	}
+
+	return r
+}
+
+func (r Run) StepOutput(name string) (*output.Log, error) {
+	if idx := slices.IndexFunc(r.Steps, func(s StepResult) bool { return s.Step == name }); idx < 0 || r.Steps[idx].Output == nil {
+		return nil, errors.New("step not found")
+	} else {
+		return r.Steps[idx].Output, nil
+	}
+}
+
+// Store persists runs as JSON files, one directory per pipeline.
+type Store struct {
+	dir string
+}
+
+func NewStore(dir string) (Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		slog.Error("Failed to create run history directory", "path", dir)
+		return Store{}, err
+	}
+
+	slog.Info("Using run history directory", "path", dir)
+
+	return Store{dir: dir}, nil
+}
+
+// Dir returns the directory the runs are stored in.
+func (s Store) Dir() string {
+	return s.dir
+}
+
+func (s Store) pipelineDir(pipeline string) string {
+	return filepath.Join(s.dir, url.PathEscape(pipeline))
+}
+
+// Save stores the run together with its summary, which is read to list the
+// runs without their outputs.
+func (s Store) Save(run Run) error {
+	dir := s.pipelineDir(run.Pipeline)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	if err := writeRun(filepath.Join(dir, run.ID+".json"), run); err != nil {
+		return err
+	}
+
+	return writeRun(filepath.Join(dir, run.ID+summarySuffix), run.Summary())
+}
+
+// Has reports whether the run with the given ID of the pipeline is stored.
+func (s Store) Has(pipeline string, id string) bool {
+	_, err := os.Stat(filepath.Join(s.pipelineDir(pipeline), id+".json"))
+	return err == nil
+}
+
+// List returns the summaries of the stored runs of a pipeline, newest first,
+// skipping the first offset runs. At most limit runs are returned, or all of
+// them if limit is 0. Only the summaries of the returned runs are read.
+func (s Store) List(pipeline string, offset int, limit int) ([]Run, error) {
+	dir := s.pipelineDir(pipeline)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, file := range files {
+		if name := filepath.Base(file); !strings.HasSuffix(name, summarySuffix) {
+			ids = append(ids, strings.TrimSuffix(name, ".json"))
+		}
+	}
+
+	// run IDs sort in the order the runs were created
+	slices.SortFunc(ids, func(a, b string) int { return strings.Compare(b, a) })
+
+	ids = ids[min(offset, len(ids)):]
+	if limit > 0 {
+		ids = ids[:min(limit, len(ids))]
+	}
+
+	runs := []Run{}
+	for _, id := range ids {
+		run, err := readSummary(dir, id)
+		if err != nil {
+			slog.Error("Error reading stored run", "pipeline", pipeline, "id", id, "error", err)
+			continue
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+// Load returns the stored run with the given ID, regardless of its pipeline.
+func (s Store) Load(id string) (Run, error) {
+	if !runIDPattern.MatchString(id) {
+		return Run{}, ErrRunNotFound
+	}
+
+	files, err := filepath.Glob(filepath.Join(s.dir, "*", id+".json"))
+	if err != nil {
+		return Run{}, err
+	}
+
+	if len(files) == 0 {
+		return Run{}, ErrRunNotFound
+	}
+
+	return readRun(files[0])
+}
+
+// readSummary reads the summary of a run, or the whole run if it has been
+// stored without one.
+func readSummary(dir string, id string) (Run, error) {
+	run, err := readRun(filepath.Join(dir, id+summarySuffix))
+	if errors.Is(err, fs.ErrNotExist) {
+		run, err = readRun(filepath.Join(dir, id+".json"))
+	}
+	if err != nil {
+		return Run{}, err
+	}
+
+	return run.Summary(), nil
+}
+
+// writeRun writes to a temporary file first, so that readers never see
+// partial runs.
+func writeRun(path string, run Run) error {
+	bytes, err := json.MarshalIndent(run, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path+".tmp", bytes, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(path+".tmp", path)
+}
+
+func readRun(path string) (Run, error) {
+	bytes, err := helper.ReadFile(path)
+	if err != nil {
+		return Run{}, err
+	}
+
+	var run Run
+	if err := json.Unmarshal(bytes, &run); err != nil {
+		return Run{}, err
+	}
+
+	return run, nil
+}
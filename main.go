@@ -12,6 +12,14 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == helper.SuperviseCommand {
+		os.Exit(helper.Supervise(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
+
 	configBaseDir, err := os.UserConfigDir()
 	if err != nil {
 		slog.Error("Failed to determine user default config location", "error", err)
@@ -0,0 +1,156 @@
+package output
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Stream string
+
+const (
+	Stdout Stream = "stdout"
+	Stderr Stream = "stderr"
+	System Stream = "system" // messages of executrix itself
+)
+
+type Line struct {
+	Seq     int       `json:"seq"`
+	Time    time.Time `json:"time"`
+	Stream  Stream    `json:"stream"`
+	Attempt int       `json:"attempt,omitempty"` // of the step, starting at 1
+	Text    string    `json:"text"`
+}
+
+// Log is the output of a step. It is safe for concurrent use. Lines are
+// numbered in the order they were appended, starting at 0.
+type Log struct {
+	mu      sync.RWMutex
+	lines   []Line
+	attempt int
+	mask    func(string) string // hides secrets in appended lines
+}
+
+func NewLog() *Log {
+	return &Log{attempt: 1}
+}
+
+// SetMask sets a function applied to the text of all lines appended from now
+// on, e.g. to hide secrets.
+func (l *Log) SetMask(mask func(string) string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.mask = mask
+}
+
+// Mask applies the mask of the log to text, so that text can be shown
+// elsewhere, e.g. in the server log.
+func (l *Log) Mask(text string) string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if l.mask == nil {
+		return text
+	}
+
+	return l.mask(text)
+}
+
+// StartAttempt marks all lines appended from now on as output of the given
+// attempt of the step.
+func (l *Log) StartAttempt(attempt int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.attempt = attempt
+}
+
+func (l *Log) Append(stream Stream, text string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.mask != nil {
+		text = l.mask(text)
+	}
+
+	l.lines = append(l.lines, Line{
+		Seq:     len(l.lines),
+		Time:    time.Now(),
+		Stream:  stream,
+		Attempt: l.attempt,
+		Text:    text,
+	})
+}
+
+// Len returns the number of lines, which is also the sequence number of the
+// next line.
+func (l *Log) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return len(l.lines)
+}
+
+func (l *Log) Lines() []Line {
+	return l.Since(0)
+}
+
+// Since returns all lines starting with sequence number seq.
+func (l *Log) Since(seq int) []Line {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if seq < 0 {
+		seq = 0
+	}
+	if seq >= len(l.lines) {
+		return []Line{}
+	}
+
+	return slices.Clone(l.lines[seq:])
+}
+
+// Tail returns the last n lines.
+func (l *Log) Tail(n int) []Line {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return slices.Clone(l.lines[max(len(l.lines)-n, 0):])
+}
+
+// String returns the text of all lines, each terminated by a newline.
+func (l *Log) String() string {
+	return Text(l.Lines())
+}
+
+// Text joins the text of the lines, each terminated by a newline.
+func Text(lines []Line) string {
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(line.Text)
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
+
+func (l *Log) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.Lines())
+}
+
+func (l *Log) UnmarshalJSON(data []byte) error {
+	var lines []Line
+	if err := json.Unmarshal(data, &lines); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.lines = lines
+
+	return nil
+}
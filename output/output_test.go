@@ -0,0 +1,61 @@
+package output
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogMask(t *testing.T) {
+	log := NewLog()
+	log.Append(Stdout, "before s3cret")
+
+	log.SetMask(func(s string) string { return strings.ReplaceAll(s, "s3cret", "***") })
+	log.Append(Stdout, "token s3cret")
+	log.Append(Stderr, "s3crets3cret")
+
+	want := "before s3cret\ntoken ***\n******\n"
+	if got := log.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	if got := log.Mask("error: s3cret"); got != "error: ***" {
+		t.Errorf("Mask = %q, want %q", got, "error: ***")
+	}
+
+	// stored runs only contain the masked lines
+	data, err := json.Marshal(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "token s3cret") {
+		t.Errorf("MarshalJSON = %s, contains secret", data)
+	}
+}
+
+func TestLogPaging(t *testing.T) {
+	log := NewLog()
+	for _, text := range []string{"a", "b", "c"} {
+		log.Append(Stdout, text)
+	}
+
+	tests := []struct {
+		name string
+		got  []Line
+		want string
+	}{
+		{"since 0", log.Since(0), "a\nb\nc\n"},
+		{"since 2", log.Since(2), "c\n"},
+		{"since end", log.Since(3), ""},
+		{"since negative", log.Since(-1), "a\nb\nc\n"},
+		{"tail 2", log.Tail(2), "b\nc\n"},
+		{"tail more", log.Tail(5), "a\nb\nc\n"},
+		{"tail 0", log.Tail(0), ""},
+	}
+
+	for _, test := range tests {
+		if got := Text(test.got); got != test.want {
+			t.Errorf("%s: %q, want %q", test.name, got, test.want)
+		}
+	}
+}
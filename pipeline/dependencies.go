@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrNoSteps         = errors.New("no steps selected")
+	ErrUnknownStep     = errors.New("unknown step")
+	ErrDependencyCycle = errors.New("dependency cycle")
+)
+
+// ResolveSteps returns the given steps together with all of their transitive
+// dependencies, ordered so that every step comes after its dependencies.
+// Unknown step names and dependency cycles are reported as errors.
+func (p Pipeline) ResolveSteps(names []string) ([]string, error) {
+	return resolveSteps(names, func(name string) ([]string, bool) {
+		s := p.FindStep(name)
+		if s == nil {
+			return nil, false
+		}
+		return s.Dependencies(), true
+	})
+}
+
+// resolveSteps implements ResolveSteps, dependencies returns the
+// dependencies of a step and whether it exists.
+func resolveSteps(names []string, dependencies func(name string) ([]string, bool)) ([]string, error) {
+	if len(names) == 0 {
+		return nil, ErrNoSteps
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	marks := make(map[string]int)
+	var plan []string
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(slices.Clone(path[slices.Index(path, name):]), name)
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(cycle, " -> "))
+		}
+
+		deps, ok := dependencies(name)
+		if !ok {
+			if len(path) > 0 {
+				return fmt.Errorf("%w: %q (dependency of %q)", ErrUnknownStep, name, path[len(path)-1])
+			}
+			return fmt.Errorf("%w: %q", ErrUnknownStep, name)
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+
+		path = path[:len(path)-1]
+		marks[name] = visited
+		plan = append(plan, name)
+
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"executrix/helper"
+	"executrix/server/config"
+)
+
+func TestResolveSteps(t *testing.T) {
+	// build <- test <- package <- deploy, lint <- package, docs
+	p := readTestPipeline(t, config.NewGlobalConfig(t.TempDir()), `{
+		"Name": "p", "Description": "", "Steps": [
+			{"Type": "Shell", "Name": "build", "Command": "true"},
+			{"Type": "Shell", "Name": "test", "Command": "true", "DependsOn": ["build"]},
+			{"Type": "Shell", "Name": "lint", "Command": "true"},
+			{"Type": "Shell", "Name": "package", "Command": "true", "DependsOn": ["test", "lint"]},
+			{"Type": "Shell", "Name": "deploy", "Command": "true", "DependsOn": ["package"]},
+			{"Type": "Link", "Name": "docs", "Link": "https://example.com"}
+		]}`)
+
+	tests := []struct {
+		steps []string
+		want  []string
+	}{
+		{[]string{"build"}, []string{"build"}},
+		{[]string{"test"}, []string{"build", "test"}},
+		{[]string{"deploy"}, []string{"build", "test", "lint", "package", "deploy"}},
+		{[]string{"lint", "deploy"}, []string{"lint", "build", "test", "package", "deploy"}},
+		{[]string{"test", "build"}, []string{"build", "test"}},
+		{[]string{"docs", "test", "test"}, []string{"docs", "build", "test"}},
+	}
+
+	for _, test := range tests {
+		got, err := p.ResolveSteps(test.steps)
+		if err != nil {
+			t.Errorf("ResolveSteps(%q): %v", test.steps, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ResolveSteps(%q) = %q, want %q", test.steps, got, test.want)
+		}
+	}
+}
+
+func TestResolveStepsErrors(t *testing.T) {
+	dependencies := map[string][]string{
+		"a":       {"b"},
+		"b":       {"c"},
+		"c":       {"a"},
+		"self":    {"self"},
+		"broken":  {"missing"},
+		"ok":      nil,
+		"cycling": {"ok", "a"},
+	}
+	lookup := func(name string) ([]string, bool) {
+		deps, ok := dependencies[name]
+		return deps, ok
+	}
+
+	tests := []struct {
+		steps []string
+		err   error
+		msg   string
+	}{
+		{nil, ErrNoSteps, "no steps selected"},
+		{[]string{"unknown"}, ErrUnknownStep, `unknown step: "unknown"`},
+		{[]string{"ok", "broken"}, ErrUnknownStep, `unknown step: "missing" (dependency of "broken")`},
+		{[]string{"a"}, ErrDependencyCycle, "dependency cycle: a -> b -> c -> a"},
+		{[]string{"cycling"}, ErrDependencyCycle, "dependency cycle: a -> b -> c -> a"},
+		{[]string{"self"}, ErrDependencyCycle, "dependency cycle: self -> self"},
+	}
+
+	for _, test := range tests {
+		_, err := resolveSteps(test.steps, lookup)
+		if !errors.Is(err, test.err) {
+			t.Errorf("resolveSteps(%q) = %v, want %v", test.steps, err, test.err)
+			continue
+		}
+		if err.Error() != test.msg {
+			t.Errorf("resolveSteps(%q) = %q, want %q", test.steps, err, test.msg)
+		}
+	}
+}
+
+func TestCheckSteps(t *testing.T) {
+	cfg := config.NewGlobalConfig(t.TempDir())
+
+	tests := []struct {
+		name  string
+		steps string
+		errs  []string
+	}{
+		{"declared twice", `{"Type": "Shell", "Name": "a", "Command": "true"}, {"Type": "Shell", "Name": "a", "Command": "true"}`,
+			[]string{`Steps[1].Name: step "a" is declared twice`}},
+		{"unknown dependency", `{"Type": "Shell", "Name": "a", "Command": "true", "DependsOn": ["b"]}`,
+			[]string{`Steps[0].DependsOn: unknown step: "b"`}},
+		{"cycle", `{"Type": "Shell", "Name": "a", "Command": "true", "DependsOn": ["b"]}, {"Type": "Shell", "Name": "b", "Command": "true", "DependsOn": ["a"]}`,
+			[]string{"Steps: dependency cycle: a -> b -> a"}},
+		// the dependencies of broken steps are checked as well
+		{"broken step", `{"Type": "Shell", "Name": "a", "DependsOn": ["b"]}`,
+			[]string{"Steps[0]: could not find script path or command", `Steps[0].DependsOn: unknown step: "b"`}},
+	}
+
+	for _, test := range tests {
+		var def Definition
+		if err := helper.DecodeStrict([]byte(`{"Name": "p", "Description": "", "Steps": [`+test.steps+`]}`), &def); err != nil {
+			t.Fatalf("%s: invalid definition: %v", test.name, err)
+		}
+
+		_, err := readPipeline(def, cfg)
+		if err == nil {
+			t.Errorf("%s: readPipeline succeeded, want errors %q", test.name, test.errs)
+			continue
+		}
+		if errs := strings.Split(err.Error(), "\n"); !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: readPipeline = %q, want %q", test.name, errs, test.errs)
+		}
+	}
+}
+
+// readTestPipeline reads a pipeline from its definition and fails the test
+// if there are any problems.
+func readTestPipeline(t *testing.T, cfg config.GlobalConfig, definition string) Pipeline {
+	t.Helper()
+
+	var def Definition
+	if err := helper.DecodeStrict([]byte(definition), &def); err != nil {
+		t.Fatalf("invalid definition: %v", err)
+	}
+
+	p, err := readPipeline(def, cfg)
+	if err != nil {
+		t.Fatalf("readPipeline: %v", err)
+	}
+
+	return p
+}
@@ -0,0 +1,120 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"executrix/helper"
+	"executrix/server/config"
+)
+
+// LoadError describes why a pipeline file could not be loaded, or, as a
+// warning, a problem of a pipeline which has been loaded anyway.
+type LoadError struct {
+	File    string `json:"file"`
+	Reason  string `json:"reason"`
+	Field   string `json:"field,omitempty"`  // path of the invalid field, e.g. Steps[2], if known
+	Line    int    `json:"line,omitempty"`   // position of invalid JSON, 0 if unknown
+	Column  int    `json:"column,omitempty"` // starting at 1
+	Warning bool   `json:"warning,omitempty"`
+}
+
+func (e LoadError) Error() string {
+	reason := e.Reason
+	if e.Warning {
+		reason = "warning: " + reason
+	}
+
+	switch {
+	case e.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, reason)
+	case e.Field != "":
+		return fmt.Sprintf("%s: %s: %s", e.File, e.Field, reason)
+	default:
+		return fmt.Sprintf("%s: %s", e.File, reason)
+	}
+}
+
+// LoadErrors are all problems found in a pipeline file.
+type LoadErrors []LoadError
+
+func (e LoadErrors) Error() string {
+	var lines []string
+	for _, loadErr := range e {
+		lines = append(lines, loadErr.Error())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// newLoadErrors describes err, which occurred while loading the file with
+// the given content. Every error joined in err becomes a LoadError.
+func newLoadErrors(file string, content []byte, err error) LoadErrors {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var loadErrs LoadErrors
+		for _, e := range joined.Unwrap() {
+			loadErrs = append(loadErrs, newLoadErrors(file, content, e)...)
+		}
+		return loadErrs
+	}
+
+	loadErr := LoadError{File: file, Reason: err.Error()}
+
+	var syntaxErr *json.SyntaxError
+	var fieldErr helper.FieldError
+	switch {
+	case errors.As(err, &syntaxErr):
+		// the offset of the decoder is just behind the offending byte
+		loadErr.Line, loadErr.Column = position(content, syntaxErr.Offset-1)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		loadErr.Reason = "unexpected end of JSON input"
+		loadErr.Line, loadErr.Column = position(content, int64(len(content))-1)
+	case errors.As(err, &fieldErr):
+		loadErr.Field = fieldErr.Field
+		loadErr.Reason = fieldErr.Err.Error()
+	}
+
+	return LoadErrors{loadErr}
+}
+
+// ValidateFile reports all problems of a pipeline file, including vars
+// which are not defined by the pipeline or the given secrets. Depending on
+// the policy of the pipeline, undefined vars are reported as warnings.
+func ValidateFile(path string, cfg config.GlobalConfig, secrets []string) LoadErrors {
+	p, loadErrs := loadPipeline(path, cfg)
+
+	return append(loadErrs, undefinedVars(path, p, secrets)...)
+}
+
+// undefinedVars reports the undefined vars of a pipeline, as warnings unless
+// the pipeline rejects them.
+func undefinedVars(path string, p Pipeline, secrets []string) LoadErrors {
+	err := p.CheckVars(secrets)
+	if err == nil {
+		return nil
+	}
+
+	loadErrs := newLoadErrors(path, nil, err)
+	if p.UndefinedVars != RejectUndefinedVars {
+		for i := range loadErrs {
+			loadErrs[i].Warning = true
+		}
+	}
+
+	return loadErrs
+}
+
+// position returns the line and column of the byte at offset.
+func position(content []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(content)))
+	before := content[:offset]
+
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
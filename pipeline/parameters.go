@@ -0,0 +1,197 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"executrix/helper"
+)
+
+// ParameterType decides which values a parameter accepts.
+type ParameterType string
+
+const (
+	StringParameter ParameterType = "string" // any text
+	ChoiceParameter ParameterType = "choice" // one of the choices of the parameter
+	BoolParameter   ParameterType = "bool"   // "true" or "false"
+)
+
+var (
+	ErrUnknownParameter = errors.New("unknown parameter")
+	ErrInvalidParameter = errors.New("invalid parameter value")
+	ErrMissingParameter = errors.New("missing parameter")
+)
+
+// Parameter is a value given when triggering the pipeline. It is referenced
+// as $(Name) in the options of the steps and substituted for each run, in
+// links when they are requested.
+type Parameter struct {
+	Name        string
+	Type        ParameterType
+	Description string
+	Default     string
+	Required    bool // there is no default, a value has to be given
+	Choices     []string
+}
+
+// check returns an error if value is not accepted by the parameter.
+func (p Parameter) check(value string) error {
+	switch p.Type {
+	case ChoiceParameter:
+		if !slices.Contains(p.Choices, value) {
+			return fmt.Errorf("%w: %q for %q, has to be one of %q", ErrInvalidParameter, value, p.Name, p.Choices)
+		}
+	case BoolParameter:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%w: %q for %q, has to be true or false", ErrInvalidParameter, value, p.Name)
+		}
+	}
+
+	return nil
+}
+
+// ResolveParameters checks the given parameter values and adds the defaults
+// of all parameters without a value. Boolean values are normalized to "true"
+// or "false".
+func (p Pipeline) ResolveParameters(values map[string]string) (map[string]string, error) {
+	for name := range values {
+		if !slices.ContainsFunc(p.Parameters, func(param Parameter) bool { return param.Name == name }) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownParameter, name)
+		}
+	}
+
+	resolved := make(map[string]string)
+	for _, param := range p.Parameters {
+		value, ok := values[param.Name]
+		if !ok {
+			if param.Required {
+				return nil, fmt.Errorf("%w: %q", ErrMissingParameter, param.Name)
+			}
+			value = param.Default
+		}
+
+		if err := param.check(value); err != nil {
+			return nil, err
+		}
+
+		if param.Type == BoolParameter {
+			b, _ := strconv.ParseBool(value)
+			value = strconv.FormatBool(b)
+		}
+
+		resolved[param.Name] = value
+	}
+
+	return resolved, nil
+}
+
+// DefaultParameters returns the defaults of all parameters which have one.
+func (p Pipeline) DefaultParameters() map[string]string {
+	defaults := make(map[string]string)
+	for _, param := range p.Parameters {
+		if !param.Required {
+			defaults[param.Name] = param.Default
+		}
+	}
+
+	return defaults
+}
+
+// ParameterDefinition is a parameter in a pipeline file.
+type ParameterDefinition struct {
+	Name        string
+	Type        ParameterType // string if not set
+	Description string
+	Default     any // string or boolean, required if not set
+	Choices     []string
+}
+
+// readParameters reads the parameters of a pipeline and reports all invalid
+// ones at once.
+func readParameters(defs []ParameterDefinition, globalVars map[string]string) ([]Parameter, error) {
+	var params []Parameter
+	var errs []error
+	for i, def := range defs {
+		field := fmt.Sprintf("[%d]", i)
+
+		param, err := readParameter(def)
+		if err != nil {
+			errs = append(errs, helper.InField(field, err))
+			continue
+		}
+
+		if slices.ContainsFunc(params, func(p Parameter) bool { return p.Name == param.Name }) {
+			errs = append(errs, helper.InField(field, fmt.Errorf("parameter %q is declared twice", param.Name)))
+			continue
+		}
+
+		// global vars are substituted when loading, they would hide the parameter
+		if _, ok := globalVars[param.Name]; ok {
+			errs = append(errs, helper.InField(field, fmt.Errorf("parameter %q has the name of a global var", param.Name)))
+			continue
+		}
+
+		params = append(params, param)
+	}
+
+	return params, errors.Join(errs...)
+}
+
+func readParameter(def ParameterDefinition) (Parameter, error) {
+	param := Parameter{
+		Name:        def.Name,
+		Type:        def.Type,
+		Description: def.Description,
+		Choices:     def.Choices,
+	}
+
+	if param.Name == "" {
+		return Parameter{}, helper.InField("Name", errors.New("could not find parameter name"))
+	}
+
+	if param.Type == "" {
+		param.Type = StringParameter
+	} else if !slices.Contains([]ParameterType{StringParameter, ChoiceParameter, BoolParameter}, param.Type) {
+		return Parameter{}, helper.InField("Type", fmt.Errorf("type of parameter %q has to be one of string, choice or bool", param.Name))
+	}
+
+	if param.Type == ChoiceParameter && len(param.Choices) == 0 {
+		return Parameter{}, helper.InField("Choices", fmt.Errorf("choice parameter %q needs a list of choices", param.Name))
+	}
+
+	switch val := def.Default.(type) {
+	case nil:
+		switch param.Type {
+		case StringParameter:
+			param.Required = true
+		case ChoiceParameter:
+			param.Default = param.Choices[0]
+		case BoolParameter:
+			param.Default = "false"
+		}
+	case string:
+		param.Default = val
+	case bool:
+		param.Default = strconv.FormatBool(val)
+	default:
+		return Parameter{}, helper.InField("Default", fmt.Errorf("unexpected type for default of parameter %q", param.Name))
+	}
+
+	if !param.Required {
+		if err := param.check(param.Default); err != nil {
+			return Parameter{}, helper.InField("Default", fmt.Errorf("invalid default: %w", err))
+		}
+	}
+
+	if param.Type == BoolParameter {
+		b, _ := strconv.ParseBool(param.Default)
+		param.Default = strconv.FormatBool(b)
+	}
+
+	slog.Debug("Read pipeline parameter", "name", param.Name, "type", param.Type, "default", param.Default)
+
+	return param, nil
+}
@@ -3,23 +3,68 @@ package pipeline
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"slices"
+	"time"
 
 	"executrix/helper"
+	"executrix/schedule"
 	"executrix/server/config"
 	"executrix/step"
 )
 
+// FailurePolicy decides how an execution continues after a step failed.
+type FailurePolicy string
+
+const (
+	FailFast FailurePolicy = "FailFast" // no further steps are started
+	RunAll   FailurePolicy = "RunAll"   // all steps not depending on the failed one are still executed
+)
+
+// QueuePolicy decides what happens to a trigger while the pipeline is running.
+type QueuePolicy string
+
+const (
+	QueueTriggers  QueuePolicy = "Queue"   // the run is started after all runs triggered before
+	RejectTriggers QueuePolicy = "Reject"  // the trigger fails
+	ReplaceRunning QueuePolicy = "Replace" // the running execution is killed and queued runs are dropped
+)
+
+// VarPolicy decides whether a pipeline referencing undefined vars is loaded.
+type VarPolicy string
+
+const (
+	WarnUndefinedVars   VarPolicy = "Warn"  // the pipeline is loaded, the references are reported as warnings
+	RejectUndefinedVars VarPolicy = "Error" // the pipeline is not loaded
+)
+
+const (
+	defaultMaxQueueLength = 10
+	defaultGracePeriod    = 10 * time.Second
+)
+
 type Pipeline struct {
-	Name        string
-	Description string
-	Steps       []step.IStep
+	Name           string
+	Description    string
+	MaxParallel    int // maximum number of steps executed at the same time
+	FailurePolicy  FailurePolicy
+	QueuePolicy    QueuePolicy
+	MaxQueueLength int
+	StepTimeout    time.Duration      // for steps without their own timeout, none if 0
+	GracePeriod    time.Duration      // between interrupting and killing a timed out step
+	UndefinedVars  VarPolicy          // for references to vars which are not defined
+	Parameters     []Parameter        // given when triggering the pipeline
+	Schedule       *schedule.Schedule // nil if the pipeline is only triggered manually
+	Steps          []step.IStep
+	stepNames      []string       // of all steps in the file, including broken ones
+	references     []varReference // vars substituted when executing the steps
 }
 
 type StateInfo struct {
-	Step  string
-	State step.State
+	Step    string
+	State   step.State
+	Attempt int `json:",omitempty"` // of the current execution, starting at 1
 }
 
 func (p Pipeline) FindStep(name string) step.IStep {
@@ -42,63 +87,286 @@ func (p Pipeline) GetStepStates() []StateInfo {
 	return list
 }
 
+// ScheduledSteps returns the steps selected by the schedule of the pipeline,
+// or its default steps if the schedule does not select any.
+func (p Pipeline) ScheduledSteps() []string {
+	if p.Schedule != nil && len(p.Schedule.Steps) > 0 {
+		return p.Schedule.Steps
+	}
+
+	var names []string
+	for _, s := range p.Steps {
+		if s.IsDefault() {
+			names = append(names, s.ShowAs())
+		}
+	}
+
+	return names
+}
+
+// Limits returns the execution limits of a step of the pipeline.
+func (p Pipeline) Limits(s step.IStep) step.Limits {
+	limits := step.Limits{
+		Timeout:     s.Timeout(),
+		GracePeriod: p.GracePeriod,
+	}
+
+	if limits.Timeout == 0 {
+		limits.Timeout = p.StepTimeout
+	}
+
+	return limits
+}
+
 func (p *Pipeline) Reset() {
 	for _, s := range p.Steps {
 		s.SetState(step.Waiting)
 	}
 }
 
-func PipelineFromJson(path string, cfg config.GlobalConfig) (Pipeline, error) {
+// Definition is the content of a pipeline file.
+type Definition struct {
+	Schema         string `json:"$schema,omitempty"` // ignored, lets editors find the JSON Schema
+	Name           string
+	Description    *string
+	MaxParallel    *int // 1 if not set
+	FailurePolicy  FailurePolicy
+	QueuePolicy    QueuePolicy
+	MaxQueueLength *int
+	StepTimeout    any // "1h30m" or a number of seconds
+	GracePeriod    any // like StepTimeout
+	UndefinedVars  VarPolicy
+	Parameters     []ParameterDefinition
+	Schedule       *schedule.Definition
+	Steps          []json.RawMessage // decoded according to their type
+}
+
+// PipelineFromJson reads a pipeline file. All problems found in the file
+// are returned at once as LoadErrors. Vars which are neither defined by the
+// pipeline nor by the given secrets are either returned as warnings or as
+// problems, depending on the UndefinedVars policy of the pipeline.
+func PipelineFromJson(path string, cfg config.GlobalConfig, secrets []string) (Pipeline, LoadErrors, error) {
+	pipeline, loadErrs := loadPipeline(path, cfg)
+	if loadErrs != nil {
+		return Pipeline{}, nil, loadErrs
+	}
+
+	warnings := undefinedVars(path, pipeline, secrets)
+	if pipeline.UndefinedVars == RejectUndefinedVars && warnings != nil {
+		return Pipeline{}, nil, warnings
+	}
+
+	return pipeline, warnings, nil
+}
+
+// loadPipeline reads a pipeline file. The pipeline is returned even if there
+// are problems, as far as it could be read.
+func loadPipeline(path string, cfg config.GlobalConfig) (Pipeline, LoadErrors) {
 	bytes, err := helper.ReadFile(path)
 	if err != nil {
-		return Pipeline{}, err
+		return Pipeline{}, newLoadErrors(path, nil, err)
 	}
 
-	var p map[string]interface{}
-	err = json.Unmarshal(bytes, &p)
-	if err != nil {
-		return Pipeline{}, err
+	// after invalid fields the rest of the file is still checked
+	var def Definition
+	decodeErr := helper.DecodeStrict(bytes, &def)
+	if fieldErr := (helper.FieldError{}); decodeErr != nil && !errors.As(decodeErr, &fieldErr) {
+		return Pipeline{}, newLoadErrors(path, bytes, decodeErr)
 	}
 
-	slog.Debug("Successfully unmarshalled file content", "content", p)
+	slog.Debug("Successfully decoded file content", "content", def)
 
-	var pipeline Pipeline
+	pipeline, err := readPipeline(def, cfg)
+	if err := errors.Join(decodeErr, err); err != nil {
+		return pipeline, newLoadErrors(path, bytes, err)
+	}
 
-	if val, ok := p["Name"].(string); !ok {
-		return Pipeline{}, errors.New("could not find pipeline name")
-	} else {
-		slog.Debug("Read pipeline name", "s", val)
-		pipeline.Name = val
+	return pipeline, nil
+}
+
+// readPipeline reads the definition of a pipeline and reports all problems at
+// once. The pipeline is returned as far as it could be read.
+func readPipeline(def Definition, cfg config.GlobalConfig) (Pipeline, error) {
+	pipeline := Pipeline{
+		Name:           def.Name,
+		MaxParallel:    1,
+		FailurePolicy:  FailFast,
+		QueuePolicy:    RejectTriggers,
+		MaxQueueLength: defaultMaxQueueLength,
+		GracePeriod:    defaultGracePeriod,
+		UndefinedVars:  WarnUndefinedVars,
 	}
+	var errs []error
 
-	if val, ok := p["Description"].(string); !ok {
-		return Pipeline{}, errors.New("could not find pipeline description")
+	if pipeline.Name == "" {
+		errs = append(errs, helper.InField("Name", errors.New("could not find pipeline name")))
+	}
+	slog.Debug("Read pipeline name", "s", pipeline.Name)
+
+	if def.Description == nil {
+		errs = append(errs, helper.InField("Description", errors.New("could not find pipeline description")))
 	} else {
-		slog.Debug("Read pipeline description", "s", val)
-		pipeline.Description = val
+		pipeline.Description = *def.Description
+		slog.Debug("Read pipeline description", "s", pipeline.Description)
+	}
+
+	if def.MaxParallel != nil {
+		if *def.MaxParallel < 1 {
+			errs = append(errs, helper.InField("MaxParallel", errors.New("max parallel has to be a positive integer")))
+		}
+		pipeline.MaxParallel = *def.MaxParallel
+		slog.Debug("Read pipeline max parallel", "n", pipeline.MaxParallel)
+	}
+
+	if def.FailurePolicy != "" {
+		if def.FailurePolicy != FailFast && def.FailurePolicy != RunAll {
+			errs = append(errs, helper.InField("FailurePolicy", errors.New("failure policy has to be either FailFast or RunAll")))
+		}
+		pipeline.FailurePolicy = def.FailurePolicy
+		slog.Debug("Read pipeline failure policy", "policy", pipeline.FailurePolicy)
 	}
 
-	val, ok := p["Steps"].([]interface{})
-	if !ok {
-		return Pipeline{}, errors.New("error reading pipeline steps")
+	if def.QueuePolicy != "" {
+		if !slices.Contains([]QueuePolicy{QueueTriggers, RejectTriggers, ReplaceRunning}, def.QueuePolicy) {
+			errs = append(errs, helper.InField("QueuePolicy", errors.New("queue policy has to be one of Queue, Reject or Replace")))
+		}
+		pipeline.QueuePolicy = def.QueuePolicy
+		slog.Debug("Read pipeline queue policy", "policy", pipeline.QueuePolicy)
 	}
 
-	slog.Debug("Read pipeline steps", "steps", val)
-	for _, elem := range val {
-		slog.Debug("Read pipeline step", "step", elem)
+	if def.MaxQueueLength != nil {
+		if *def.MaxQueueLength < 1 {
+			errs = append(errs, helper.InField("MaxQueueLength", errors.New("max queue length has to be a positive integer")))
+		}
+		pipeline.MaxQueueLength = *def.MaxQueueLength
+		slog.Debug("Read pipeline max queue length", "n", pipeline.MaxQueueLength)
+	}
 
-		val, ok := elem.(map[string]interface{})
-		if !ok {
-			return Pipeline{}, errors.New("unexpected type for step")
+	if def.StepTimeout != nil {
+		timeout, err := helper.ParseDuration(def.StepTimeout)
+		if err != nil {
+			errs = append(errs, helper.InField("StepTimeout", fmt.Errorf("invalid step timeout: %w", err)))
 		}
+		slog.Debug("Read pipeline step timeout", "timeout", timeout)
+		pipeline.StepTimeout = timeout
+	}
 
-		step, err := step.StepFromJSON(val, cfg)
+	if def.GracePeriod != nil {
+		grace, err := helper.ParseDuration(def.GracePeriod)
 		if err != nil {
-			return Pipeline{}, err
+			errs = append(errs, helper.InField("GracePeriod", fmt.Errorf("invalid grace period: %w", err)))
+		}
+		slog.Debug("Read pipeline grace period", "grace", grace)
+		pipeline.GracePeriod = grace
+	}
+
+	if def.UndefinedVars != "" {
+		if def.UndefinedVars != WarnUndefinedVars && def.UndefinedVars != RejectUndefinedVars {
+			errs = append(errs, helper.InField("UndefinedVars", errors.New("undefined vars policy has to be either Warn or Error")))
+		}
+		pipeline.UndefinedVars = def.UndefinedVars
+		slog.Debug("Read pipeline undefined vars policy", "policy", pipeline.UndefinedVars)
+	}
+
+	params, err := readParameters(def.Parameters, cfg.GetVars())
+	if err != nil {
+		errs = append(errs, helper.InField("Parameters", err))
+	}
+	pipeline.Parameters = params
+
+	if def.Steps == nil {
+		errs = append(errs, helper.InField("Steps", errors.New("error reading pipeline steps")))
+	}
+
+	// the names and dependencies of broken steps are still checked
+	var heads []stepHead
+	for i, raw := range def.Steps {
+		field := fmt.Sprintf("Steps[%d]", i)
+
+		var head stepHead
+		json.Unmarshal(raw, &head)
+		heads = append(heads, head)
+		pipeline.stepNames = append(pipeline.stepNames, head.Name)
+		pipeline.references = append(pipeline.references, findStepReferences(field, raw, cfg)...)
+
+		step, err := step.StepFromJSON(raw, cfg)
+		if err != nil {
+			errs = append(errs, helper.InField(field, err))
+			continue
 		}
 
 		pipeline.Steps = append(pipeline.Steps, step)
 	}
 
-	return pipeline, nil
+	if err := checkSteps(heads); err != nil {
+		errs = append(errs, err)
+	}
+
+	// checks involving broken steps or parameters would only report follow-up problems
+	stepsRead := len(pipeline.Steps) == len(def.Steps)
+	paramsRead := len(pipeline.Parameters) == len(def.Parameters)
+
+	if def.Schedule != nil {
+		schedule, err := schedule.FromDefinition(*def.Schedule)
+		if err != nil {
+			errs = append(errs, helper.InField("Schedule", err))
+		} else {
+			pipeline.Schedule = schedule
+		}
+
+		if err == nil && stepsRead {
+			if _, err := pipeline.ResolveSteps(pipeline.ScheduledSteps()); err != nil {
+				errs = append(errs, helper.InField("Schedule.Steps", fmt.Errorf("invalid scheduled steps: %w", err)))
+			}
+		}
+		if err == nil && paramsRead {
+			if _, err := pipeline.ResolveParameters(schedule.Parameters); err != nil {
+				errs = append(errs, helper.InField("Schedule.Parameters", fmt.Errorf("invalid scheduled parameters: %w", err)))
+			}
+		}
+	}
+
+	return pipeline, errors.Join(errs...)
+}
+
+// stepHead holds the options of a step needed to check the dependencies,
+// which are read even if the step is broken.
+type stepHead struct {
+	Name      string
+	DependsOn []string
+}
+
+// checkSteps reports steps declared twice, dependencies on unknown steps and
+// dependency cycles.
+func checkSteps(heads []stepHead) error {
+	var errs []error
+
+	dependencies := make(map[string][]string)
+	var names []string
+	for i, head := range heads {
+		if _, ok := dependencies[head.Name]; ok && head.Name != "" {
+			errs = append(errs, helper.InField(fmt.Sprintf("Steps[%d].Name", i), fmt.Errorf("step %q is declared twice", head.Name)))
+			continue
+		}
+		dependencies[head.Name] = head.DependsOn
+		names = append(names, head.Name)
+	}
+
+	for i, head := range heads {
+		for _, dep := range head.DependsOn {
+			if _, ok := dependencies[dep]; !ok {
+				errs = append(errs, helper.InField(fmt.Sprintf("Steps[%d].DependsOn", i), fmt.Errorf("%w: %q", ErrUnknownStep, dep)))
+			}
+		}
+	}
+
+	// unknown dependencies are reported above, here they only end the path
+	_, err := resolveSteps(names, func(name string) ([]string, bool) {
+		return dependencies[name], true
+	})
+	if errors.Is(err, ErrDependencyCycle) {
+		errs = append(errs, helper.InField("Steps", err))
+	}
+
+	return errors.Join(errs...)
 }
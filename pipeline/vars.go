@@ -0,0 +1,143 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"executrix/helper"
+	"executrix/server/config"
+	"executrix/step"
+)
+
+// varPattern matches a $(name) substituted in the options of a step, and
+// escaped ones like $$(name), which are not. The name is anything but spaces
+// and parentheses, so that typos like $(env:NAME) are found as well. Uses of
+// $(...) with spaces, e.g. command substitution like $(cat file) in shell
+// commands, are left alone. Command substitution without arguments like
+// $(whoami) is reported as an undefined var unless it is escaped as
+// $$(whoami).
+var varPattern = regexp.MustCompile(`(\$?)\$\(([^\s()]+)\)`)
+
+// DateVar is substituted with the date a run was started, e.g. 2024-05-31.
+// A shell command like $(date) is not a var and runs as it is.
+const DateVar = "executrix.date"
+
+// varReference is a $(name) in an option of a step, which is substituted
+// when the step is executed.
+type varReference struct {
+	field      string // e.g. Steps[1].Arguments[0]
+	name       string
+	paramsOnly bool // in a link, only parameters are substituted
+	global     bool // in the value of a global var referenced by the option
+}
+
+// findStepReferences returns the vars referenced by the options of a step,
+// including those in the values of the global vars it references. Global
+// vars and environment variables are left out, they have been substituted
+// when loading, as well as references with a fallback.
+func findStepReferences(field string, raw json.RawMessage, cfg config.GlobalConfig) []varReference {
+	var options map[string]any
+	if err := json.Unmarshal(raw, &options); err != nil {
+		return nil
+	}
+
+	isLink := options["Type"] == "Link"
+
+	// neither is substituted
+	delete(options, "Type")
+	delete(options, "Name")
+	delete(options, "DependsOn")
+
+	var refs []varReference
+	for _, key := range sortedKeys(options) {
+		refs = append(refs, findReferences(field+"."+key, options[key], cfg)...)
+	}
+
+	for i := range refs {
+		refs[i].paramsOnly = isLink
+	}
+
+	return refs
+}
+
+func findReferences(field string, val any, cfg config.GlobalConfig) []varReference {
+	var refs []varReference
+
+	switch v := val.(type) {
+	case string:
+		// the references left after loading, as the step sees them
+		for _, match := range varPattern.FindAllStringSubmatch(cfg.Substitute(v), -1) {
+			if match[1] == "" && !strings.Contains(match[2], ":-") {
+				global := !strings.Contains(v, "$("+match[2]+")")
+				refs = append(refs, varReference{field: field, name: match[2], global: global})
+			}
+		}
+	case []any:
+		for i, elem := range v {
+			refs = append(refs, findReferences(fmt.Sprintf("%s[%d]", field, i), elem, cfg)...)
+		}
+	case map[string]any:
+		for _, key := range sortedKeys(v) {
+			refs = append(refs, findReferences(field+"."+key, v[key], cfg)...)
+		}
+	}
+
+	return refs
+}
+
+// CheckVars reports every var referenced by the steps which is neither a
+// parameter of the pipeline, an output variable of one of its steps, the
+// date of the run nor one of the given secrets, and every var in a link
+// which is not a parameter, as well as environment variables which are not
+// set. Such references would be left as they are.
+func (p Pipeline) CheckVars(secrets []string) error {
+	var errs []error
+	for _, ref := range p.references {
+		env, isEnv := strings.CutPrefix(ref.name, config.EnvPrefix)
+
+		undefined := "undefined var $(" + ref.name + ")"
+		if ref.global {
+			undefined += " in the value of a global var"
+		}
+
+		switch {
+		case isEnv:
+			errs = append(errs, helper.InField(ref.field, fmt.Errorf("environment variable %s is not set, write $(%s:-fallback) to use a fallback", env, ref.name)))
+		case ref.paramsOnly && !p.hasParameter(ref.name):
+			errs = append(errs, helper.InField(ref.field, fmt.Errorf("%s, only parameters are substituted in links", undefined)))
+		case !ref.paramsOnly && !p.definesVar(ref.name) && !slices.Contains(secrets, ref.name):
+			errs = append(errs, helper.InField(ref.field, fmt.Errorf("%s, write $$(%s) to keep it as it is", undefined, ref.name)))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p Pipeline) hasParameter(name string) bool {
+	return slices.ContainsFunc(p.Parameters, func(param Parameter) bool { return param.Name == name })
+}
+
+func (p Pipeline) definesVar(name string) bool {
+	if name == DateVar || p.hasParameter(name) {
+		return true
+	}
+
+	// step.OutputVar, the name of the output is only known when executing
+	return slices.ContainsFunc(p.stepNames, func(s string) bool {
+		return strings.HasPrefix(name, step.OutputVar(s, ""))
+	})
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
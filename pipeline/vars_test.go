@@ -0,0 +1,93 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"executrix/server/config"
+)
+
+func TestCheckVars(t *testing.T) {
+	t.Setenv("EXECUTRIX_TEST_HOME", "/home/test")
+
+	cfg := testGlobalConfig(t, `{"vars": [
+		{"name": "out", "value": "/srv/out"},
+		{"name": "repo", "value": "/srv/$(branch)"},
+		{"name": "broken", "value": "/srv/$(nope)"}
+	]}`)
+
+	tests := []struct {
+		name  string
+		steps string
+		errs  []string
+	}{
+		{"parameter", `{"Type": "Shell", "Name": "a", "Command": "git checkout $(branch)"}`, nil},
+		{"global var", `{"Type": "Shell", "Name": "a", "Command": "ls $(out)"}`, nil},
+		{"built-in vars", `{"Type": "Shell", "Name": "a", "Command": "echo $(executrix.date) $(executrix.hostname) $(executrix.configDir)"}`, nil},
+		{"secret", `{"Type": "Shell", "Name": "a", "Command": "login $(TOKEN)"}`, nil},
+		{"output var", `{"Type": "Shell", "Name": "a", "Command": "true"}, {"Type": "Shell", "Name": "b", "Command": "echo $(steps.a.version)", "DependsOn": ["a"]}`, nil},
+		{"environment variable", `{"Type": "Shell", "Name": "a", "Command": "echo $(env:EXECUTRIX_TEST_HOME)"}`, nil},
+		{"fallbacks", `{"Type": "Shell", "Name": "a", "Command": "echo $(unknown:-x) $(env:EXECUTRIX_TEST_UNSET:-x)"}`, nil},
+		{"escaped", `{"Type": "Shell", "Name": "a", "Command": "echo $$(date) $$(unknown)"}`, nil},
+		{"command substitution with arguments", `{"Type": "Shell", "Name": "a", "Command": "echo $(date +%F)"}`, nil},
+		{"parameter in global var", `{"Type": "Shell", "Name": "a", "Command": "cd $(repo)"}`, nil},
+		{"parameter in link", `{"Type": "Link", "Name": "a", "Link": "https://example.com/$(branch)/$(repo)"}`, nil},
+
+		{"undefined var", `{"Type": "Shell", "Name": "a", "Command": "echo $(unknown)"}`,
+			[]string{"Steps[0].Command: undefined var $(unknown), write $$(unknown) to keep it as it is"}},
+		{"command substitution", `{"Type": "Shell", "Name": "a", "Command": "echo $(date)"}`,
+			[]string{"Steps[0].Command: undefined var $(date), write $$(date) to keep it as it is"}},
+		{"output var of unknown step", `{"Type": "Shell", "Name": "a", "Command": "echo $(steps.b.version)"}`,
+			[]string{"Steps[0].Command: undefined var $(steps.b.version), write $$(steps.b.version) to keep it as it is"}},
+		{"unset environment variable", `{"Type": "Shell", "Name": "a", "Command": "echo $(env:EXECUTRIX_TEST_UNSET)"}`,
+			[]string{"Steps[0].Command: environment variable EXECUTRIX_TEST_UNSET is not set, write $(env:EXECUTRIX_TEST_UNSET:-fallback) to use a fallback"}},
+		{"undefined var in global var", `{"Type": "Shell", "Name": "a", "Command": "cd $(broken)"}`,
+			[]string{"Steps[0].Command: undefined var $(nope) in the value of a global var, write $$(nope) to keep it as it is"}},
+		{"secret in link", `{"Type": "Link", "Name": "a", "Link": "https://example.com/?token=$(TOKEN)&d=$(executrix.date)"}`, []string{
+			"Steps[0].Link: undefined var $(TOKEN), only parameters are substituted in links",
+			"Steps[0].Link: undefined var $(executrix.date), only parameters are substituted in links",
+		}},
+		{"nested options", `{"Type": "Shell", "Name": "a", "Command": "true", "Arguments": ["$(x)"], "Env": {"B": "$(y)", "A": "$(z)"}}`, []string{
+			"Steps[0].Arguments[0]: undefined var $(x), write $$(x) to keep it as it is",
+			"Steps[0].Env.A: undefined var $(z), write $$(z) to keep it as it is",
+			"Steps[0].Env.B: undefined var $(y), write $$(y) to keep it as it is",
+		}},
+	}
+
+	for _, test := range tests {
+		p := readTestPipeline(t, cfg, `{
+			"Name": "p", "Description": "",
+			"Parameters": [{"Name": "branch", "Default": "main"}],
+			"Steps": [`+test.steps+`]}`)
+
+		err := p.CheckVars([]string{"TOKEN"})
+
+		var errs []string
+		if err != nil {
+			errs = strings.Split(err.Error(), "\n")
+		}
+		if !reflect.DeepEqual(errs, test.errs) {
+			t.Errorf("%s: CheckVars = %q, want %q", test.name, errs, test.errs)
+		}
+	}
+}
+
+// testGlobalConfig reads a global config file with the given content.
+func testGlobalConfig(t *testing.T, content string) config.GlobalConfig {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "globalconfig.json")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.GlobalConfigFromJson(path)
+	if err != nil {
+		t.Fatalf("GlobalConfigFromJson: %v", err)
+	}
+
+	return cfg
+}
@@ -0,0 +1,149 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCronErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"", "has to consist of 5 fields"},
+		{"* * * *", "has to consist of 5 fields"},
+		{"* * * * * *", "has to consist of 5 fields"},
+		{"60 * * * *", "value 60 out of range 0-59 in minute field"},
+		{"* 24 * * *", "value 24 out of range 0-23 in hour field"},
+		{"* * 0 * *", "value 0 out of range 1-31 in day of month field"},
+		{"* * * 13 *", "value 13 out of range 1-12 in month field"},
+		{"* * * * 8", "value 8 out of range 0-7 in day of week field"},
+		{"* * * foo *", `invalid value "foo" in month field`},
+		{"10-5 * * * *", `invalid range "10-5" in minute field`},
+		{"*/0 * * * *", `invalid step "0" in minute field`},
+		{"*/x * * * *", `invalid step "x" in minute field`},
+		{"1,,2 * * * *", `invalid value "" in minute field`},
+		{"@fortnightly", "has to consist of 5 fields"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseCron(test.expr)
+		if err == nil {
+			t.Errorf("ParseCron(%q) succeeded, want error containing %q", test.expr, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseCron(%q) = %q, want error containing %q", test.expr, err, test.err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", "2024-05-14 10:20:30", "2024-05-14 10:21:00"},
+		{"later today", "30 2 * * *", "2024-05-14 01:00:00", "2024-05-14 02:30:00"},
+		{"tomorrow", "30 2 * * *", "2024-05-14 02:30:00", "2024-05-15 02:30:00"},
+		{"step from value", "5/15 * * * *", "2024-05-14 10:21:00", "2024-05-14 10:35:00"},
+		{"step in range", "10-40/20 * * * *", "2024-05-14 10:31:00", "2024-05-14 11:10:00"},
+		{"list", "0 8,12 * * *", "2024-05-14 09:00:00", "2024-05-14 12:00:00"},
+		{"month rollover", "0 0 1 * *", "2024-01-31 12:00:00", "2024-02-01 00:00:00"},
+		{"year rollover", "0 0 * * *", "2024-12-31 23:59:00", "2025-01-01 00:00:00"},
+		{"leap day", "0 0 29 2 *", "2023-03-01 00:00:00", "2024-02-29 00:00:00"},
+		{"month names", "0 0 1 jun-aug *", "2024-09-01 00:00:00", "2025-06-01 00:00:00"},
+		{"weekdays", "0 9 * * mon-fri", "2024-05-17 10:00:00", "2024-05-20 09:00:00"},
+		{"sunday as 7", "0 0 * * 7", "2024-05-14 00:00:00", "2024-05-19 00:00:00"},
+		// 2024-05-15 is a wednesday, the 20th a monday
+		{"day of month or week", "0 0 20 * 3", "2024-05-14 00:00:00", "2024-05-15 00:00:00"},
+		{"day of week or month", "0 0 15 * 1", "2024-05-15 00:00:00", "2024-05-20 00:00:00"},
+		{"macro", "@weekly", "2024-05-14 00:00:00", "2024-05-19 00:00:00"},
+		{"never", "0 0 30 2 *", "2024-01-01 00:00:00", ""},
+	}
+
+	for _, test := range tests {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("%s: ParseCron(%q): %v", test.name, test.expr, err)
+		}
+
+		from := parseTime(t, test.from, time.UTC)
+		got := c.Next(from)
+
+		if test.want == "" {
+			if !got.IsZero() {
+				t.Errorf("%s: Next(%s) = %s, want zero time", test.name, from, got)
+			}
+			continue
+		}
+
+		if want := parseTime(t, test.want, time.UTC); !got.Equal(want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", test.name, test.expr, from, got, want)
+		}
+	}
+}
+
+func TestCronNextDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data not available:", err)
+	}
+
+	tests := []struct {
+		name string
+		expr string
+		from time.Time
+		want time.Time
+	}{
+		// on 2024-03-31 the clocks jump from 02:00 CET to 03:00 CEST
+		{"skipped time", "30 2 * * *",
+			time.Date(2024, 3, 31, 1, 0, 0, 0, berlin),
+			time.Date(2024, 4, 1, 2, 30, 0, 0, berlin)},
+		{"after skipped hour", "0 3 * * *",
+			time.Date(2024, 3, 31, 1, 0, 0, 0, berlin),
+			time.Date(2024, 3, 31, 3, 0, 0, 0, berlin)},
+		{"hourly over skipped hour", "15 * * * *",
+			time.Date(2024, 3, 31, 1, 30, 0, 0, berlin),
+			time.Date(2024, 3, 31, 1, 15, 0, 0, time.UTC)}, // 03:15 CEST
+		// on 2024-10-27 the clocks go back from 03:00 CEST to 02:00 CET
+		{"first of repeated times", "30 2 * * *",
+			time.Date(2024, 10, 27, 1, 0, 0, 0, berlin),
+			time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC)}, // 02:30 CEST
+		{"repeated time only once", "30 2 * * *",
+			time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC).In(berlin),
+			time.Date(2024, 10, 28, 2, 30, 0, 0, berlin)},
+		{"hourly over repeated hour", "15 * * * *",
+			time.Date(2024, 10, 27, 0, 15, 0, 0, time.UTC).In(berlin), // 02:15 CEST
+			time.Date(2024, 10, 27, 2, 15, 0, 0, time.UTC)},           // 03:15 CET
+	}
+
+	for _, test := range tests {
+		c, err := ParseCron(test.expr)
+		if err != nil {
+			t.Fatalf("%s: ParseCron(%q): %v", test.name, test.expr, err)
+		}
+
+		got := c.Next(test.from)
+		if !got.Equal(test.want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", test.name, test.expr, test.from, got, test.want.In(berlin))
+		}
+		if got.Location() != berlin {
+			t.Errorf("%s: Next(%s) is in %s, want %s", test.name, test.from, got.Location(), berlin)
+		}
+	}
+}
+
+func parseTime(t *testing.T, s string, loc *time.Location) time.Time {
+	t.Helper()
+
+	tm, err := time.ParseInLocation(time.DateTime, s, loc)
+	if err != nil {
+		t.Fatalf("invalid time %q: %v", s, err)
+	}
+
+	return tm
+}
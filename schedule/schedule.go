@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"executrix/helper"
+
+	// timezones have to be available on systems without zoneinfo, e.g. windows
+	_ "time/tzdata"
+)
+
+// Schedule describes when a pipeline is triggered automatically.
+type Schedule struct {
+	Cron       []Cron
+	Steps      []string          // steps to run, the default steps of the pipeline if empty
+	Parameters map[string]string // values of the pipeline parameters, defaults if missing
+	Location   *time.Location
+}
+
+// Definition is the "Schedule" section of a pipeline file, e.g.
+//
+//	{"Cron": ["0 2 * * 1-5"], "Steps": ["Build"], "Parameters": {"Branch": "main"}, "Timezone": "Europe/Berlin"}
+//
+// Steps can be omitted or set to "default" to run the default steps. Without
+// parameters the defaults are used, without a timezone the local time of the
+// server.
+type Definition struct {
+	Cron       []string
+	Steps      any            // list of step names or "default"
+	Parameters map[string]any // strings or booleans
+	Timezone   string
+}
+
+// FromDefinition reads the schedule of a pipeline and reports all invalid
+// options at once.
+func FromDefinition(def Definition) (*Schedule, error) {
+	schedule := Schedule{Location: time.Local}
+	var errs []error
+
+	if len(def.Cron) == 0 {
+		errs = append(errs, helper.InField("Cron", errors.New("schedule needs a list of cron expressions")))
+	}
+
+	for i, expr := range def.Cron {
+		cron, err := ParseCron(expr)
+		if err != nil {
+			errs = append(errs, helper.InField(fmt.Sprintf("Cron[%d]", i), err))
+			continue
+		}
+
+		schedule.Cron = append(schedule.Cron, cron)
+		slog.Debug("Read schedule cron expression", "cron", expr)
+	}
+
+	if err := readSteps(&schedule, def.Steps); err != nil {
+		errs = append(errs, helper.InField("Steps", err))
+	}
+
+	if def.Parameters != nil {
+		schedule.Parameters = make(map[string]string)
+
+		// sorted, so that problems are always reported in the same order
+		names := make([]string, 0, len(def.Parameters))
+		for name := range def.Parameters {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+
+		for _, name := range names {
+			switch v := def.Parameters[name].(type) {
+			case string:
+				schedule.Parameters[name] = v
+			case bool:
+				schedule.Parameters[name] = strconv.FormatBool(v)
+			default:
+				errs = append(errs, helper.InField("Parameters", fmt.Errorf("unexpected type for schedule parameter %q", name)))
+			}
+		}
+	}
+
+	if def.Timezone != "" {
+		loc, err := time.LoadLocation(def.Timezone)
+		if err != nil {
+			errs = append(errs, helper.InField("Timezone", fmt.Errorf("unknown schedule timezone %q: %w", def.Timezone, err)))
+		} else {
+			schedule.Location = loc
+			slog.Debug("Read schedule timezone", "timezone", def.Timezone)
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if next, _ := schedule.Next(time.Now()); next.IsZero() {
+		return nil, helper.InField("Cron", errors.New("schedule never triggers"))
+	}
+
+	return &schedule, nil
+}
+
+func readSteps(schedule *Schedule, steps any) error {
+	errSteps := errors.New("schedule steps have to be a list of step names or \"default\"")
+
+	switch val := steps.(type) {
+	case nil:
+	case string:
+		if !strings.EqualFold(val, "default") {
+			return errSteps
+		}
+	case []interface{}:
+		for _, v := range val {
+			name, ok := v.(string)
+			if !ok {
+				return errSteps
+			}
+			schedule.Steps = append(schedule.Steps, name)
+		}
+	default:
+		return errSteps
+	}
+
+	return nil
+}
+
+// Next returns the first time after t at which the pipeline is triggered,
+// together with the cron expression causing it. The zero time is returned if
+// no expression matches within the next five years.
+func (s *Schedule) Next(t time.Time) (time.Time, Cron) {
+	var next time.Time
+	var cause Cron
+
+	for _, c := range s.Cron {
+		if n := c.Next(t.In(s.Location)); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+			cause = c
+		}
+	}
+
+	return next, cause
+}
+
+// NextRun returns the next time the pipeline is triggered.
+func (s *Schedule) NextRun() time.Time {
+	next, _ := s.Next(time.Now())
+	return next
+}
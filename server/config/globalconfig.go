@@ -3,12 +3,25 @@ package config
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
 
 	"executrix/helper"
 )
 
+// globalConfigFile is the content of the global config file.
+type globalConfigFile struct {
+	OutputDir string      `json:"outputDir"`
+	Vars      []globalVar `json:"vars"`
+}
+
+type globalVar struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+}
+
 type GlobalConfig struct {
 	vars      map[string]string
 	outputDir string
@@ -30,6 +43,14 @@ func (cfg GlobalConfig) GetOutputDir() string {
 	return cfg.outputDir
 }
 
+// NewGlobalConfig returns the config used without a global config file,
+// which only has the built-in vars.
+func NewGlobalConfig(configDir string) GlobalConfig {
+	return GlobalConfig{vars: builtinVars(configDir)}
+}
+
+// GlobalConfigFromJson reads the global config file at path and resolves
+// its vars, see resolveVars. The file is created if it does not exist.
 func GlobalConfigFromJson(path string) (GlobalConfig, error) {
 	pathExists, err := helper.Exists(path)
 	if err != nil {
@@ -50,66 +71,61 @@ func GlobalConfigFromJson(path string) (GlobalConfig, error) {
 		return GlobalConfig{}, err
 	}
 
-	var p map[string]interface{}
-	err = json.Unmarshal(bytes, &p)
-	if err != nil {
+	var file globalConfigFile
+	if err := helper.DecodeStrict(bytes, &file); err != nil {
 		return GlobalConfig{}, err
 	}
 
-	slog.Debug("Successfully unmarshalled file content", "content", p)
+	slog.Debug("Successfully decoded file content", "content", file)
 
 	cfg := GlobalConfig{}
 
-	val, ok := p["outputDir"].(string)
-	if !ok {
-		return GlobalConfig{}, errors.New("unexpected type for outputDir")
+	// the output dir is optional, the server falls back to a default location
+	if file.OutputDir != "" {
+		slog.Info("Read output dir", "dir", file.OutputDir)
+		cfg.outputDir = file.OutputDir
 	}
 
-	slog.Info("Read output dir", "dir", val)
-	cfg.outputDir = val
-
-	list, ok := p["vars"].([]interface{})
-	if !ok {
+	if file.Vars == nil {
 		return GlobalConfig{}, errors.New("error reading global vars")
 	}
 
-	slog.Debug("Read global vars", "vars", list)
-	vars := map[string]string{}
-	for _, elem := range list {
-		slog.Info("Read global var", "var", elem)
+	var names []string
+	values := map[string]string{}
+	for _, v := range file.Vars {
+		slog.Info("Read global var", "var", v.Name)
 
-		pair, ok := elem.(map[string]interface{})
-		if !ok {
-			return GlobalConfig{}, errors.New("unexpected type for pair")
-		}
-
-		name, ok := pair["name"].(string)
-		if !ok {
-			return GlobalConfig{}, errors.New("unexpected type for name")
+		if v.Name == "" {
+			return GlobalConfig{}, errors.New("could not find name of global var")
 		}
 
 		// check if name has already been used
-		if _, ok := vars[name]; ok {
+		if _, ok := values[v.Name]; ok {
 			return GlobalConfig{}, errors.New("found non-unique name in vars")
 		}
 
-		value, ok := pair["value"].(string)
-		if !ok {
-			return GlobalConfig{}, errors.New("unexpected type for value")
+		if v.Value == nil {
+			return GlobalConfig{}, fmt.Errorf("could not find value of global var %q", v.Name)
 		}
 
-		vars[name] = value
+		names = append(names, v.Name)
+		values[v.Name] = *v.Value
 	}
 
+	vars, err := resolveVars(names, values, builtinVars(filepath.Dir(path)))
+	if err != nil {
+		return GlobalConfig{}, err
+	}
 	cfg.vars = vars
 
 	return cfg, nil
 }
 
 func createDefaultGlobalConfig(path string) error {
-	// todo: use marshalling
-	data := []byte("{\n" +
-		"\t\"vars\": []\n" +
-		"}\n")
-	return os.WriteFile(path, data, 0644)
+	data, err := json.MarshalIndent(globalConfigFile{Vars: []globalVar{}}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
 }
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// checkPrivate returns an error if the file can be accessed by other users
+// than its owner.
+func checkPrivate(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return fmt.Errorf("%s must only be accessible by its owner, but has permissions %s", path, perm)
+	}
+
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package config
+
+// checkPrivate does nothing on windows, where access is restricted by ACLs
+// instead of permission bits.
+func checkPrivate(path string) error {
+	return nil
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"executrix/constants"
+	"executrix/helper"
+)
+
+// secretsFile is the content of the secrets file.
+type secretsFile struct {
+	Secrets []secret `json:"secrets"`
+}
+
+type secret struct {
+	Name  string  `json:"name"`
+	Value *string `json:"value"`
+	Env   string  `json:"env"` // name of the environment variable holding the value
+}
+
+// Secrets are variables whose values must not be shown anywhere. They are
+// substituted for $(name) when a step is executed, never when loading the
+// pipelines, and their values are masked in all output.
+type Secrets struct {
+	vars     map[string]string
+	replacer *strings.Replacer
+}
+
+// SecretsFromJson reads the secrets file, which must only be accessible by
+// its owner, and the secrets given by environment variables. The file is
+// optional and looks like
+//
+//	{"secrets": [{"name": "TOKEN", "value": "..."}, {"name": "KEY", "env": "DEPLOY_KEY"}]}
+//
+// where "env" reads the value from an environment variable of the server.
+func SecretsFromJson(path string) (Secrets, error) {
+	vars := map[string]string{}
+
+	// e.g. EXECUTRIX_SECRET_TOKEN provides the secret TOKEN
+	for _, entry := range os.Environ() {
+		if rest, ok := strings.CutPrefix(entry, constants.SECRET_ENV_PREFIX); ok {
+			if name, value, ok := strings.Cut(rest, "="); ok && name != "" {
+				slog.Info("Read secret from environment", "name", name)
+				vars[name] = value
+			}
+		}
+	}
+
+	pathExists, err := helper.Exists(path)
+	if err != nil {
+		slog.Error("Failed checking for secrets path", "path", path)
+		return Secrets{}, err
+	}
+
+	if pathExists {
+		if err := checkPrivate(path); err != nil {
+			return Secrets{}, err
+		}
+
+		if err := readSecretsFile(path, vars); err != nil {
+			return Secrets{}, err
+		}
+	}
+
+	return NewSecrets(vars), nil
+}
+
+func readSecretsFile(path string, vars map[string]string) error {
+	bytes, err := helper.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file secretsFile
+	if err := helper.DecodeStrict(bytes, &file); err != nil {
+		return err
+	}
+
+	if file.Secrets == nil {
+		return errors.New("error reading secrets")
+	}
+
+	fromFile := map[string]bool{}
+	for _, secret := range file.Secrets {
+		name := secret.Name
+		if name == "" {
+			return errors.New("could not find secret name")
+		}
+
+		if fromFile[name] {
+			return errors.New("found non-unique name in secrets")
+		}
+		fromFile[name] = true
+
+		switch {
+		case secret.Value != nil && secret.Env != "":
+			return fmt.Errorf("secret %q needs either a value or an env, not both", name)
+		case secret.Value != nil:
+			vars[name] = *secret.Value
+		case secret.Env != "":
+			value, ok := os.LookupEnv(secret.Env)
+			if !ok {
+				return fmt.Errorf("environment variable %q of secret %q is not set", secret.Env, name)
+			}
+			vars[name] = value
+		default:
+			return fmt.Errorf("secret %q needs either a value or an env", name)
+		}
+
+		slog.Info("Read secret", "name", name)
+	}
+
+	return nil
+}
+
+func NewSecrets(vars map[string]string) Secrets {
+	// longer values first, so that secrets containing others are fully masked
+	values := make([]string, 0, len(vars))
+	for _, value := range vars {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+
+	var pairs []string
+	for _, value := range values {
+		pairs = append(pairs, value, "***")
+	}
+
+	return Secrets{
+		vars:     vars,
+		replacer: strings.NewReplacer(pairs...),
+	}
+}
+
+func (s Secrets) GetVars() map[string]string {
+	return s.vars
+}
+
+// Names returns the names of all secrets.
+func (s Secrets) Names() []string {
+	names := make([]string, 0, len(s.vars))
+	for name := range s.vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// Mask replaces all secret values in text with "***".
+func (s Secrets) Mask(text string) string {
+	if s.replacer == nil {
+		return text
+	}
+
+	return s.replacer.Replace(text)
+}
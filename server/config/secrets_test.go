@@ -0,0 +1,48 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSecretsMask(t *testing.T) {
+	secrets := NewSecrets(map[string]string{
+		"TOKEN":  "abc123",
+		"PREFIX": "abc",
+		"LONG":   "abc123xyz",
+		"EMPTY":  "",
+	})
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"", ""},
+		{"nothing secret", "nothing secret"},
+		{"token=abc123", "token=***"},
+		{"abc123abc123", "******"},
+		{"abc", "***"},
+		// longer secrets are masked before the ones they contain
+		{"abc123xyz", "***"},
+		{"abc123x", "***x"},
+		{"ab c123", "ab c123"},
+	}
+
+	for _, test := range tests {
+		if got := secrets.Mask(test.text); got != test.want {
+			t.Errorf("Mask(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+
+	if want := []string{"EMPTY", "LONG", "PREFIX", "TOKEN"}; !reflect.DeepEqual(secrets.Names(), want) {
+		t.Errorf("Names() = %q, want %q", secrets.Names(), want)
+	}
+}
+
+func TestSecretsMaskWithoutSecrets(t *testing.T) {
+	for _, secrets := range []Secrets{{}, NewSecrets(nil), NewSecrets(map[string]string{"EMPTY": ""})} {
+		if got := secrets.Mask("text"); got != "text" {
+			t.Errorf("Mask(%q) = %q without secrets", "text", got)
+		}
+	}
+}
@@ -12,6 +12,11 @@ import (
 	"executrix/helper"
 )
 
+// serverConfigFile is the content of the server config file.
+type serverConfigFile struct {
+	Port string `json:"port"`
+}
+
 type ServerConfig struct {
 	configDir   string
 	pipelineDir string
@@ -41,21 +46,20 @@ func ServerConfigFromJson(configDir string) (ServerConfig, error) {
 		return ServerConfig{}, err
 	}
 
-	var p map[string]interface{}
-	err = json.Unmarshal(bytes, &p)
-	if err != nil {
+	var file serverConfigFile
+	if err := helper.DecodeStrict(bytes, &file); err != nil {
 		return ServerConfig{}, err
 	}
 
-	slog.Debug("Successfully unmarshalled file content", "content", p)
+	slog.Debug("Successfully decoded file content", "content", file)
 
 	var config ServerConfig
 	config.configDir = configDir
 	config.pipelineDir = pipelineDir
 
 	// reading server port from config
-	val, ok := p["port"].(string)
-	if !ok {
+	val := file.Port
+	if val == "" {
 		return ServerConfig{}, errors.New("could not read server port from config")
 	} else {
 		slog.Debug("Read server port", "port", val)
@@ -83,9 +87,10 @@ func (s ServerConfig) GetPipelineDir() string {
 }
 
 func createDefaultServerConfig(path string) error {
-	// todo: use marshalling
-	data := []byte("{\n" +
-		"\t\"port\": \"8111\"\n" +
-		"}\n")
-	return os.WriteFile(path, data, 0644)
+	data, err := json.MarshalIndent(serverConfigFile{Port: "8111"}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, append(data, '\n'), 0644)
 }
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+
+	"executrix/helper"
+)
+
+// EnvPrefix marks a reference to an environment variable of the server, e.g.
+// $(env:HOME).
+const EnvPrefix = "env:"
+
+// Built-in global vars, a global var of the same name takes precedence. Like
+// all built-in vars, they are prefixed with "executrix.", so that they do not
+// collide with command substitution like $(hostname) in shell commands.
+const (
+	HostnameVar  = "executrix.hostname"  // name of the machine running the server
+	ConfigDirVar = "executrix.configDir" // directory of the global config file
+)
+
+// builtinVars returns the values of the built-in global vars.
+func builtinVars(configDir string) map[string]string {
+	vars := map[string]string{ConfigDirVar: configDir}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		slog.Warn("Failed to read hostname, $("+HostnameVar+") is not defined", "err", err)
+	} else {
+		vars[HostnameVar] = hostname
+	}
+
+	return vars
+}
+
+// varResolver substitutes the references in the values of the global vars.
+type varResolver struct {
+	values   map[string]string // as declared in the config file
+	builtins map[string]string
+	resolved map[string]string
+	path     []string // vars being resolved, to find cycles
+	err      error
+}
+
+// resolveVars resolves the global vars in the order they are declared. A
+// value may contain
+//
+//   - $(name), the value of another global var or of a built-in var,
+//   - $(env:NAME), the environment variable NAME of the server,
+//   - $(name:-fallback) and $(env:NAME:-fallback), where fallback is used
+//     if the var is empty or the environment variable is not set.
+//
+// Other references, e.g. to parameters, are kept and substituted when a step
+// is executed, with their fallback if they have one. A var referring back to
+// itself is an error.
+func resolveVars(names []string, values map[string]string, builtins map[string]string) (map[string]string, error) {
+	r := varResolver{
+		values:   values,
+		builtins: builtins,
+		resolved: make(map[string]string),
+	}
+
+	for _, name := range names {
+		if _, err := r.resolve(name); err != nil {
+			return nil, err
+		}
+	}
+
+	vars := r.resolved
+	for name, value := range builtins {
+		if _, ok := vars[name]; !ok {
+			vars[name] = value
+		}
+	}
+
+	return vars, nil
+}
+
+func (r *varResolver) resolve(name string) (string, error) {
+	if value, ok := r.resolved[name]; ok {
+		return value, nil
+	}
+
+	if i := slices.Index(r.path, name); i >= 0 {
+		cycle := append(slices.Clone(r.path[i:]), name)
+		return "", fmt.Errorf("global var %q refers to itself: %s", name, strings.Join(cycle, " -> "))
+	}
+
+	r.path = append(r.path, name)
+	value := helper.ReplaceFunc(r.values[name], r.lookup)
+	r.path = r.path[:len(r.path)-1]
+
+	if r.err != nil {
+		return "", r.err
+	}
+
+	slog.Debug("Resolved global var", "var", name)
+	r.resolved[name] = value
+
+	return value, nil
+}
+
+// lookup returns the value of a reference in the value of a global var and
+// false if it is kept for later.
+func (r *varResolver) lookup(ref string) (string, bool) {
+	if r.err != nil {
+		return "", false
+	}
+
+	name, fallback, hasFallback := strings.Cut(ref, ":-")
+
+	if env, ok := strings.CutPrefix(name, EnvPrefix); ok {
+		value, set := os.LookupEnv(env)
+		switch {
+		case value == "" && hasFallback:
+			return fallback, true
+		case !set:
+			r.err = fmt.Errorf("global var %q: environment variable %s is not set", r.path[len(r.path)-1], env)
+			return "", false
+		}
+		return value, true
+	}
+
+	var value string
+	if _, ok := r.values[name]; ok {
+		var err error
+		if value, err = r.resolve(name); err != nil {
+			r.err = err
+			return "", false
+		}
+	} else if builtin, ok := r.builtins[name]; ok {
+		value = builtin
+	} else {
+		return "", false
+	}
+
+	if value == "" && hasFallback {
+		return fallback, true
+	}
+
+	return value, true
+}
+
+// Substitute replaces the references to global vars and to environment
+// variables in an option of a step when loading a pipeline, including those
+// with a fallback like $(name:-fallback). Other references are kept, they are
+// substituted when the step is executed.
+func (cfg GlobalConfig) Substitute(s string) string {
+	return helper.ReplaceFunc(s, cfg.lookup)
+}
+
+func (cfg GlobalConfig) lookup(ref string) (string, bool) {
+	name, fallback, hasFallback := strings.Cut(ref, ":-")
+
+	var value string
+	if env, ok := strings.CutPrefix(name, EnvPrefix); ok {
+		var set bool
+		if value, set = os.LookupEnv(env); !set && !hasFallback {
+			return "", false
+		}
+	} else if value, ok = cfg.vars[name]; !ok {
+		return "", false
+	}
+
+	if value == "" && hasFallback {
+		return fallback, true
+	}
+
+	return value, true
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveVars(t *testing.T) {
+	t.Setenv("EXECUTRIX_TEST_HOME", "/home/test")
+	t.Setenv("EXECUTRIX_TEST_EMPTY", "")
+
+	builtins := map[string]string{HostnameVar: "host", ConfigDirVar: "/etc/executrix"}
+
+	tests := []struct {
+		name string
+		vars [][2]string // in declared order
+		want map[string]string
+	}{
+		{"plain", [][2]string{{"a", "1"}}, map[string]string{"a": "1"}},
+		{"earlier var", [][2]string{{"a", "1"}, {"b", "$(a)/2"}}, map[string]string{"a": "1", "b": "1/2"}},
+		{"later var", [][2]string{{"b", "$(a)/2"}, {"a", "1"}}, map[string]string{"a": "1", "b": "1/2"}},
+		{"chain", [][2]string{{"c", "$(b)/3"}, {"b", "$(a)/2"}, {"a", "1"}}, map[string]string{"a": "1", "b": "1/2", "c": "1/2/3"}},
+		{"built-in", [][2]string{{"a", "$(executrix.hostname):$(executrix.configDir)"}}, map[string]string{"a": "host:/etc/executrix"}},
+		{"built-in overridden", [][2]string{{HostnameVar, "other"}, {"a", "$(executrix.hostname)"}}, map[string]string{HostnameVar: "other", "a": "other"}},
+		{"environment", [][2]string{{"a", "$(env:EXECUTRIX_TEST_HOME)/x"}}, map[string]string{"a": "/home/test/x"}},
+		{"environment fallback", [][2]string{{"a", "$(env:EXECUTRIX_TEST_UNSET:-/tmp) $(env:EXECUTRIX_TEST_EMPTY:-empty)"}}, map[string]string{"a": "/tmp empty"}},
+		{"var fallback", [][2]string{{"a", ""}, {"b", "$(a:-x) $(param:-y)"}}, map[string]string{"a": "", "b": "x $(param:-y)"}},
+		{"kept", [][2]string{{"a", "$(branch) $$(a) $(date +%F)"}}, map[string]string{"a": "$(branch) $$(a) $(date +%F)"}},
+	}
+
+	for _, test := range tests {
+		var names []string
+		values := map[string]string{}
+		for _, v := range test.vars {
+			names = append(names, v[0])
+			values[v[0]] = v[1]
+		}
+
+		got, err := resolveVars(names, values, builtins)
+		if err != nil {
+			t.Errorf("%s: resolveVars: %v", test.name, err)
+			continue
+		}
+
+		for name, want := range test.want {
+			if got[name] != want {
+				t.Errorf("%s: $(%s) = %q, want %q", test.name, name, got[name], want)
+			}
+		}
+		for name, want := range builtins {
+			if _, ok := values[name]; !ok && got[name] != want {
+				t.Errorf("%s: built-in $(%s) = %q, want %q", test.name, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestResolveVarsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		vars [][2]string
+		err  string
+	}{
+		{"itself", [][2]string{{"a", "$(a)"}}, `global var "a" refers to itself: a -> a`},
+		{"cycle", [][2]string{{"a", "$(b)"}, {"b", "$(c)"}, {"c", "$(a)"}}, `global var "a" refers to itself: a -> b -> c -> a`},
+		{"cycle after chain", [][2]string{{"x", "$(a)"}, {"a", "$(b)"}, {"b", "$(a)"}}, `global var "a" refers to itself: a -> b -> a`},
+		{"cycle with fallback", [][2]string{{"a", "$(b:-x)"}, {"b", "$(a)"}}, `global var "a" refers to itself: a -> b -> a`},
+		{"unset environment variable", [][2]string{{"a", "$(env:EXECUTRIX_TEST_UNSET)"}}, `global var "a": environment variable EXECUTRIX_TEST_UNSET is not set`},
+		{"nested unset environment variable", [][2]string{{"a", "$(b)"}, {"b", "$(env:EXECUTRIX_TEST_UNSET)"}}, `global var "b": environment variable EXECUTRIX_TEST_UNSET is not set`},
+	}
+
+	for _, test := range tests {
+		var names []string
+		values := map[string]string{}
+		for _, v := range test.vars {
+			names = append(names, v[0])
+			values[v[0]] = v[1]
+		}
+
+		_, err := resolveVars(names, values, nil)
+		if err == nil {
+			t.Errorf("%s: resolveVars succeeded, want error containing %q", test.name, test.err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: resolveVars = %q, want error containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	t.Setenv("EXECUTRIX_TEST_HOME", "/home/test")
+
+	cfg := GlobalConfig{vars: map[string]string{"out": "/srv/out", "empty": ""}}
+
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{"$(out)/x", "/srv/out/x"},
+		{"$(env:EXECUTRIX_TEST_HOME)", "/home/test"},
+		{"$(env:EXECUTRIX_TEST_UNSET)", "$(env:EXECUTRIX_TEST_UNSET)"},
+		{"$(env:EXECUTRIX_TEST_UNSET:-x)", "x"},
+		{"$(empty:-x) $(out:-x)", "x /srv/out"},
+		// left for the run
+		{"$(branch) $(branch:-main)", "$(branch) $(branch:-main)"},
+		{"$$(out)", "$$(out)"},
+	}
+
+	for _, test := range tests {
+		if got := cfg.Substitute(test.s); got != test.want {
+			t.Errorf("Substitute(%q) = %q, want %q", test.s, got, test.want)
+		}
+	}
+}
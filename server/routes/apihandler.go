@@ -0,0 +1,618 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"executrix/data"
+	"executrix/executrix"
+	"executrix/history"
+	"executrix/output"
+	"executrix/pipeline"
+	server "executrix/server/state"
+	"executrix/step"
+)
+
+// error codes of the API, see apiError
+const (
+	codeNotFound         = "not_found"
+	codeMethodNotAllowed = "method_not_allowed"
+	codePipelineNotFound = "pipeline_not_found"
+	codeRunNotFound      = "run_not_found"
+	codeStepNotFound     = "step_not_found"
+	codeNoExecution      = "no_execution"
+	codeInvalidBody      = "invalid_body"
+	codeInvalidParameter = "invalid_parameter"
+	codeNoSteps          = "no_steps"
+	codeUnknownStep      = "unknown_step"
+	codeDependencyCycle  = "dependency_cycle"
+	codeAlreadyRunning   = "already_running"
+	codeNotReset         = "not_reset"
+	codeNotRunning       = "not_running"
+	codeQueueFull        = "queue_full"
+	codeUnknownParameter = "unknown_parameter"
+	codeInvalidValue     = "invalid_parameter_value"
+	codeMissingParameter = "missing_parameter"
+	codeNotQueued        = "run_not_queued"
+	codeInternal         = "internal_error"
+)
+
+// APIHandler serves the versioned REST API under /api/v1/:
+//
+//	GET    /api/v1/pipelines
+//	GET    /api/v1/pipelines/{name}
+//	GET    /api/v1/pipelines/{name}/steps
+//	GET    /api/v1/pipelines/{name}/steps/{step}/output[?since=N|tail=N]
+//	GET    /api/v1/pipelines/{name}/steps/{step}/link[?{param}=value...]
+//	GET    /api/v1/pipelines/{name}/runs
+//	POST   /api/v1/pipelines/{name}/runs           trigger, body {"steps": [...], "parameters": {...}}
+//	GET    /api/v1/pipelines/{name}/execution      current run
+//	DELETE /api/v1/pipelines/{name}/execution      reset
+//	POST   /api/v1/pipelines/{name}/kill
+//	GET    /api/v1/pipelines/{name}/queue
+//	DELETE /api/v1/pipelines/{name}/queue/{id}    cancel a queued run
+//	GET    /api/v1/runs/{id}
+//	GET    /api/v1/runs/{id}/steps/{step}/output[?since=N|tail=N]
+//	POST   /api/v1/reload                          reload the pipeline files
+//	GET    /api/v1/load-errors                     pipeline files which could not be loaded
+//
+// Errors are answered with a matching status code and an apiError body.
+type APIHandler struct {
+	state  server.IServerState
+	routes []apiRoute
+}
+
+// apiError is the body of all error responses. Code is meant for programs
+// and does not change, Message is meant for humans.
+type apiError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// apiRoute maps a path pattern to handlers per HTTP method. A "*" in the
+// pattern matches any single path segment, which is passed to the handler.
+type apiRoute struct {
+	pattern  []string
+	handlers map[string]func(w http.ResponseWriter, r *http.Request, args []string)
+}
+
+type apiStep struct {
+	Name            string   `json:"name"`
+	Type            string   `json:"type"`
+	State           string   `json:"state"`
+	DependsOn       []string `json:"dependsOn"`
+	ContinueOnError bool     `json:"continueOnError"`
+	Link            string   `json:"link,omitempty"` // with the parameters of the current run or the defaults
+}
+
+type apiLink struct {
+	Link string `json:"link"`
+}
+
+type apiParameter struct {
+	Name        string                 `json:"name"`
+	Type        pipeline.ParameterType `json:"type"`
+	Description string                 `json:"description,omitempty"`
+	Default     *string                `json:"default,omitempty"` // nil if a value is required
+	Choices     []string               `json:"choices,omitempty"`
+}
+
+type apiPipeline struct {
+	Name          string                 `json:"name"`
+	Description   string                 `json:"description"`
+	MaxParallel   int                    `json:"maxParallel"`
+	FailurePolicy pipeline.FailurePolicy `json:"failurePolicy"`
+	Running       bool                   `json:"running"`
+	RunID         string                 `json:"runId,omitempty"`   // of the current execution
+	NextRun       *time.Time             `json:"nextRun,omitempty"` // planned by the schedule
+	Parameters    []apiParameter         `json:"parameters"`
+	Steps         []apiStep              `json:"steps"`
+}
+
+type apiTriggerRequest struct {
+	Steps      []string       `json:"steps"`
+	Parameters map[string]any `json:"parameters"` // defaults are used for missing values
+}
+
+type apiTriggerResponse struct {
+	RunID  string   `json:"runId"`
+	Plan   []string `json:"plan"`
+	Queued bool     `json:"queued"`
+}
+
+func NewAPIHandler(state server.IServerState) APIHandler {
+	h := APIHandler{
+		state: state,
+	}
+
+	h.routes = []apiRoute{
+		{[]string{"pipelines"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.listPipelines,
+		}},
+		{[]string{"pipelines", "*"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.getPipeline,
+		}},
+		{[]string{"pipelines", "*", "steps"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.listSteps,
+		}},
+		{[]string{"pipelines", "*", "steps", "*", "output"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.getStepOutput,
+		}},
+		{[]string{"pipelines", "*", "steps", "*", "link"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.getStepLink,
+		}},
+		{[]string{"pipelines", "*", "runs"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet:  h.listRuns,
+			http.MethodPost: h.trigger,
+		}},
+		{[]string{"pipelines", "*", "execution"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet:    h.getExecution,
+			http.MethodDelete: h.reset,
+		}},
+		{[]string{"pipelines", "*", "kill"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodPost: h.kill,
+		}},
+		{[]string{"pipelines", "*", "queue"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.listQueue,
+		}},
+		{[]string{"pipelines", "*", "queue", "*"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodDelete: h.cancelQueued,
+		}},
+		{[]string{"runs", "*"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.getRun,
+		}},
+		{[]string{"runs", "*", "steps", "*", "output"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.getRunStepOutput,
+		}},
+		{[]string{"reload"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodPost: h.reload,
+		}},
+		{[]string{"load-errors"}, map[string]func(http.ResponseWriter, *http.Request, []string){
+			http.MethodGet: h.listLoadErrors,
+		}},
+	}
+
+	return h
+}
+
+func (h APIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Request to API", "method", r.Method, "path", r.URL.Path)
+	slog.Debug("Request to API", "request", *r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	segments := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/"), "/"), "/")
+
+	for _, route := range h.routes {
+		args, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+
+		handler, ok := route.handlers[r.Method]
+		if !ok {
+			var allowed []string
+			for method := range route.handlers {
+				allowed = append(allowed, method)
+			}
+			slices.Sort(allowed)
+
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method "+r.Method+" is not allowed, use "+strings.Join(allowed, " or "))
+			return
+		}
+
+		handler(w, r, args)
+		return
+	}
+
+	writeAPIError(w, http.StatusNotFound, codeNotFound, "no such endpoint: "+r.URL.Path)
+}
+
+func (route apiRoute) match(segments []string) ([]string, bool) {
+	if len(segments) != len(route.pattern) {
+		return nil, false
+	}
+
+	var args []string
+	for i, part := range route.pattern {
+		switch {
+		case part == "*" && segments[i] != "":
+			args = append(args, segments[i])
+		case part != segments[i]:
+			return nil, false
+		}
+	}
+
+	return args, true
+}
+
+func writeAPIError(w http.ResponseWriter, status int, code string, message string) {
+	slog.Error("API request failed", "status", status, "code", code, "message", message)
+
+	w.WriteHeader(status)
+	writeJSON(w, apiError{Code: code, Message: message})
+}
+
+// pipelineOrError returns the named pipeline or answers the request with an
+// error if it does not exist.
+func (h APIHandler) pipelineOrError(w http.ResponseWriter, name string) *pipeline.Pipeline {
+	p := h.state.PipelineFromName(name)
+	if p == nil {
+		writeAPIError(w, http.StatusNotFound, codePipelineNotFound, "unknown pipeline: "+name)
+	}
+
+	return p
+}
+
+func (h APIHandler) describePipeline(p *pipeline.Pipeline) apiPipeline {
+	result := apiPipeline{
+		Name:          p.Name,
+		Description:   p.Description,
+		MaxParallel:   p.MaxParallel,
+		FailurePolicy: p.FailurePolicy,
+		Parameters:    []apiParameter{},
+		Steps:         describeSteps(p, h.linkParameters(p)),
+	}
+
+	for _, param := range p.Parameters {
+		described := apiParameter{
+			Name:        param.Name,
+			Type:        param.Type,
+			Description: param.Description,
+			Choices:     param.Choices,
+		}
+		if !param.Required {
+			def := param.Default
+			described.Default = &def
+		}
+
+		result.Parameters = append(result.Parameters, described)
+	}
+
+	if p.Schedule != nil {
+		next := p.Schedule.NextRun()
+		result.NextRun = &next
+	}
+
+	if exec := h.state.Execution(p.Name); exec != nil {
+		result.Running = !exec.IsFinished()
+		result.RunID = exec.ID()
+	}
+
+	return result
+}
+
+func describeSteps(p *pipeline.Pipeline, params map[string]string) []apiStep {
+	steps := []apiStep{}
+	for _, s := range p.Steps {
+		described := apiStep{
+			Name:            s.ShowAs(),
+			Type:            s.Type(),
+			State:           s.GetState().String(),
+			DependsOn:       append([]string{}, s.Dependencies()...),
+			ContinueOnError: s.ContinuesOnError(),
+		}
+		if link, ok := s.(*step.LinkStep); ok {
+			described.Link = link.Resolve(params)
+		}
+
+		steps = append(steps, described)
+	}
+
+	return steps
+}
+
+// linkParameters returns the parameter values substituted in the links of
+// the steps of p: those of the current run, or the defaults.
+func (h APIHandler) linkParameters(p *pipeline.Pipeline) map[string]string {
+	if exec := h.state.Execution(p.Name); exec != nil {
+		return exec.Parameters()
+	}
+
+	return p.DefaultParameters()
+}
+
+func (h APIHandler) listPipelines(w http.ResponseWriter, r *http.Request, args []string) {
+	pipelines := []apiPipeline{}
+	for _, p := range h.state.AllPipelines() {
+		pipelines = append(pipelines, h.describePipeline(p))
+	}
+
+	writeJSON(w, pipelines)
+}
+
+func (h APIHandler) getPipeline(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p != nil {
+		writeJSON(w, h.describePipeline(p))
+	}
+}
+
+func (h APIHandler) listSteps(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p != nil {
+		writeJSON(w, describeSteps(p, h.linkParameters(p)))
+	}
+}
+
+// getStepLink returns the link of a step with the parameter values given in
+// the query substituted, using the defaults for missing ones.
+func (h APIHandler) getStepLink(w http.ResponseWriter, r *http.Request, args []string) {
+	p := h.pipelineOrError(w, args[0])
+	if p == nil {
+		return
+	}
+
+	link, ok := p.FindStep(args[1]).(*step.LinkStep)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, codeStepNotFound, "no link step: "+args[1])
+		return
+	}
+
+	values := make(map[string]string)
+	for name := range r.URL.Query() {
+		values[name] = r.URL.Query().Get(name)
+	}
+
+	params, err := p.ResolveParameters(values)
+	if err != nil {
+		status, code := triggerErrorStatus(err)
+		writeAPIError(w, status, code, err.Error())
+		return
+	}
+
+	writeJSON(w, apiLink{Link: link.Resolve(params)})
+}
+
+func (h APIHandler) getStepOutput(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p == nil {
+		return
+	}
+
+	exec := h.state.Execution(args[0])
+	if exec == nil {
+		writeAPIError(w, http.StatusNotFound, codeNoExecution, "pipeline has no current execution: "+args[0])
+		return
+	}
+
+	log, err := exec.StepOutput(args[1])
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, codeStepNotFound, "no output for step: "+args[1])
+		return
+	}
+
+	lines, err := pageOutput(log, r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	writeJSON(w, outputResponse{Text: output.Text(lines), Lines: lines})
+}
+
+func (h APIHandler) listRuns(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p == nil {
+		return
+	}
+
+	offset, limit, err := pageRuns(r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	runs, err := h.state.Runs(args[0], offset, limit)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not list runs: "+err.Error())
+		return
+	}
+
+	writeJSON(w, runs)
+}
+
+func (h APIHandler) trigger(w http.ResponseWriter, r *http.Request, args []string) {
+	p := h.pipelineOrError(w, args[0])
+	if p == nil {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var request apiTriggerRequest
+	if err := decoder.Decode(&request); err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidBody, "invalid request body: "+err.Error())
+		return
+	}
+
+	var stepInfo []data.StepInfo
+	for _, name := range request.Steps {
+		stepInfo = append(stepInfo, data.StepInfo{StepName: name, Checked: true})
+	}
+
+	params, err := parameterValues(request.Parameters)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidValue, err.Error())
+		return
+	}
+
+	exec, queued, err := h.state.Trigger(p, stepInfo, params, triggeredBy(r))
+	if err != nil {
+		status, code := triggerErrorStatus(err)
+		writeAPIError(w, status, code, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	writeJSON(w, apiTriggerResponse{RunID: exec.ID(), Plan: exec.Plan(), Queued: queued})
+}
+
+func triggerErrorStatus(err error) (int, string) {
+	switch {
+	case errors.Is(err, server.ErrAlreadyRunning):
+		return http.StatusConflict, codeAlreadyRunning
+	case errors.Is(err, server.ErrNotReset):
+		return http.StatusConflict, codeNotReset
+	case errors.Is(err, server.ErrQueueFull):
+		return http.StatusConflict, codeQueueFull
+	case errors.Is(err, pipeline.ErrNoSteps):
+		return http.StatusBadRequest, codeNoSteps
+	case errors.Is(err, pipeline.ErrUnknownStep):
+		return http.StatusBadRequest, codeUnknownStep
+	case errors.Is(err, pipeline.ErrUnknownParameter):
+		return http.StatusBadRequest, codeUnknownParameter
+	case errors.Is(err, pipeline.ErrInvalidParameter):
+		return http.StatusBadRequest, codeInvalidValue
+	case errors.Is(err, pipeline.ErrMissingParameter):
+		return http.StatusBadRequest, codeMissingParameter
+	case errors.Is(err, pipeline.ErrDependencyCycle):
+		return http.StatusUnprocessableEntity, codeDependencyCycle
+	default:
+		return http.StatusInternalServerError, codeInternal
+	}
+}
+
+func (h APIHandler) listQueue(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p == nil {
+		return
+	}
+
+	writeJSON(w, queueSummaries(h.state.Queue(args[0])))
+}
+
+func (h APIHandler) cancelQueued(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p == nil {
+		return
+	}
+
+	if err := h.state.CancelQueued(args[0], args[1]); err != nil {
+		if errors.Is(err, server.ErrNotQueued) {
+			writeAPIError(w, http.StatusNotFound, codeNotQueued, "run is not queued: "+args[1])
+		} else {
+			writeAPIError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h APIHandler) getExecution(w http.ResponseWriter, r *http.Request, args []string) {
+	if exec := h.executionOrError(w, args[0]); exec != nil {
+		writeJSON(w, exec.Record().Summary())
+	}
+}
+
+// executionOrError returns the current execution of the named pipeline or
+// answers the request with an error if there is none.
+func (h APIHandler) executionOrError(w http.ResponseWriter, name string) *executrix.Execution {
+	if p := h.pipelineOrError(w, name); p == nil {
+		return nil
+	}
+
+	exec := h.state.Execution(name)
+	if exec == nil {
+		writeAPIError(w, http.StatusNotFound, codeNoExecution, "pipeline has no current execution: "+name)
+	}
+
+	return exec
+}
+
+func (h APIHandler) reset(w http.ResponseWriter, r *http.Request, args []string) {
+	if p := h.pipelineOrError(w, args[0]); p == nil {
+		return
+	}
+
+	if err := h.state.Reset(args[0]); err != nil {
+		if errors.Is(err, server.ErrAlreadyRunning) {
+			writeAPIError(w, http.StatusConflict, codeAlreadyRunning, err.Error())
+		} else {
+			writeAPIError(w, http.StatusInternalServerError, codeInternal, err.Error())
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h APIHandler) kill(w http.ResponseWriter, r *http.Request, args []string) {
+	exec := h.executionOrError(w, args[0])
+	if exec == nil {
+		return
+	}
+
+	if exec.IsFinished() {
+		writeAPIError(w, http.StatusConflict, codeNotRunning, "pipeline is not running: "+args[0])
+		return
+	}
+
+	if err := h.state.Kill(args[0]); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not kill pipeline: "+err.Error())
+		return
+	}
+
+	writeJSON(w, exec.Record().Summary())
+}
+
+func (h APIHandler) runOrError(w http.ResponseWriter, id string) (history.Run, bool) {
+	run, err := h.state.Run(id)
+	if errors.Is(err, history.ErrRunNotFound) {
+		writeAPIError(w, http.StatusNotFound, codeRunNotFound, "unknown run: "+id)
+		return history.Run{}, false
+	} else if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not load run: "+err.Error())
+		return history.Run{}, false
+	}
+
+	return run, true
+}
+
+func (h APIHandler) getRun(w http.ResponseWriter, r *http.Request, args []string) {
+	if run, ok := h.runOrError(w, args[0]); ok {
+		writeJSON(w, run.Summary())
+	}
+}
+
+func (h APIHandler) getRunStepOutput(w http.ResponseWriter, r *http.Request, args []string) {
+	run, ok := h.runOrError(w, args[0])
+	if !ok {
+		return
+	}
+
+	log, err := run.StepOutput(args[1])
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, codeStepNotFound, "no output for step: "+args[1])
+		return
+	}
+
+	lines, err := pageOutput(log, r.URL.Query())
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	writeJSON(w, outputResponse{Text: output.Text(lines), Lines: lines})
+}
+
+func (h APIHandler) reload(w http.ResponseWriter, r *http.Request, args []string) {
+	reloadPipelines(w, h.state)
+}
+
+func (h APIHandler) listLoadErrors(w http.ResponseWriter, r *http.Request, args []string) {
+	writeJSON(w, h.state.LoadErrors())
+}
+
+// reloadPipelines reloads the pipeline files and answers with the result.
+func reloadPipelines(w http.ResponseWriter, state server.IServerState) {
+	result, err := state.Reload()
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, codeInternal, "could not reload pipelines: "+err.Error())
+		return
+	}
+
+	writeJSON(w, result)
+}
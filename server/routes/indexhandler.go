@@ -24,7 +24,5 @@ func (h IndexHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// todo check there's nothing after '/'
 
-	// reload pipeline files (if pipelines no are running)
-
 	h.page.Execute(w, h.data)
 }
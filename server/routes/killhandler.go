@@ -2,7 +2,6 @@ package routes
 
 import (
 	server "executrix/server/state"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -27,9 +26,9 @@ func (h KillHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	name := strings.TrimPrefix(r.URL.Path, "/kill/")
 	if err := h.state.Kill(name); err != nil {
 		slog.Error("Could not cancel pipeline", "error", err)
-		fmt.Fprint(w, `{"success": false}`) // todo error handling
+		writeJSON(w, successResponse{Success: false, Error: err.Error()})
 	} else {
-		fmt.Fprint(w, `{"success": true}`)
+		writeJSON(w, successResponse{Success: true})
 	}
 
 }
@@ -2,7 +2,6 @@ package routes
 
 import (
 	server "executrix/server/state"
-	"fmt"
 	"log/slog"
 	"net/http"
 	"strings"
@@ -28,8 +27,8 @@ func (h NewRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	if err := h.state.Reset(name); err != nil {
 		slog.Error("Could not reset pipeline", "error", err)
-		fmt.Fprint(w, `{"success": false}`) // todo error handling
+		writeJSON(w, successResponse{Success: false, Error: err.Error()})
 	} else {
-		fmt.Fprint(w, `{"success": true}`)
+		writeJSON(w, successResponse{Success: true})
 	}
 }
@@ -1,12 +1,14 @@
 package routes
 
 import (
-	"fmt"
+	"errors"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
-	"executrix/helper"
+	"executrix/output"
 	server "executrix/server/state"
 )
 
@@ -26,14 +28,46 @@ func (h OutputHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	name := strings.TrimPrefix(r.URL.Path, "/output/")
-	output, err := h.state.StepOutput(name)
+	pipeline, name, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/output/"), "/")
+	log, err := h.state.StepOutput(pipeline, name)
 	if err != nil {
-		slog.Error("Error retrieving step output", "step", name)
-		fmt.Fprint(w, `{"text": "Error retrieving step output!"}`)
+		slog.Error("Error retrieving step output", "pipeline", pipeline, "step", name)
+		writeJSON(w, outputError("Error retrieving step output!"))
 		return
 	}
 
-	slog.Debug("Sending output", "step", name, "text", output)
-	fmt.Fprint(w, `{"text": "`+helper.ForJSON(output)+`"}`)
+	lines, err := pageOutput(log, r.URL.Query())
+	if err != nil {
+		slog.Error("Invalid output page", "step", name, "error", err)
+		writeJSON(w, outputError("Error retrieving step output: "+err.Error()))
+		return
+	}
+
+	slog.Debug("Sending output", "step", name, "lines", len(lines))
+	writeJSON(w, outputResponse{Text: output.Text(lines), Lines: lines})
+}
+
+// pageOutput returns the lines of the log starting at the sequence number
+// given by the since parameter, or the last lines as given by the tail
+// parameter. Without either parameter all lines are returned.
+func pageOutput(log *output.Log, query url.Values) ([]output.Line, error) {
+	if val := query.Get("since"); val != "" {
+		since, err := strconv.Atoi(val)
+		if err != nil || since < 0 {
+			return nil, errors.New("since has to be a non-negative number")
+		}
+
+		return log.Since(since), nil
+	}
+
+	if val := query.Get("tail"); val != "" {
+		tail, err := strconv.Atoi(val)
+		if err != nil || tail < 0 {
+			return nil, errors.New("tail has to be a non-negative number")
+		}
+
+		return log.Tail(tail), nil
+	}
+
+	return log.Lines(), nil
 }
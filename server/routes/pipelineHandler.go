@@ -1,6 +1,9 @@
 package routes
 
 import (
+	"errors"
+	"executrix/history"
+	"executrix/pipeline"
 	server "executrix/server/state"
 	"log/slog"
 	"net/http"
@@ -8,15 +11,34 @@ import (
 	"text/template"
 )
 
+// PipelineHandler serves the pages of a pipeline: the pipeline itself under
+// /pipeline/{name}, its run history under /pipeline/{name}/runs and a read-only
+// view of a previous run under /pipeline/{name}/runs/{id}.
 type PipelineHandler struct {
 	page      template.Template
+	runsPage  template.Template
 	pipelines server.IPipelineContainer
+	runs      server.IRunHistory
 }
 
-func NewPipelineHandler(page template.Template, pipelines server.IPipelineContainer) PipelineHandler {
+type pipelinePageData struct {
+	*pipeline.Pipeline
+	RunID string // set for the read-only view of a previous run
+}
+
+type runsPageData struct {
+	Name  string
+	Runs  []history.Run
+	Newer int // offset of the page of newer runs, -1 if there are none
+	Older int // offset of the page of older runs, -1 if there may be none
+}
+
+func NewPipelineHandler(page template.Template, runsPage template.Template, pipelines server.IPipelineContainer, runs server.IRunHistory) PipelineHandler {
 	return PipelineHandler{
 		page:      page,
+		runsPage:  runsPage,
 		pipelines: pipelines,
+		runs:      runs,
 	}
 }
 
@@ -24,12 +46,58 @@ func (h PipelineHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Request pipeline page")
 	slog.Debug("Request pipeline page", "request", *r)
 
-	name := strings.TrimPrefix(r.URL.Path, "/pipeline/")
+	name, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/pipeline/"), "/")
 
-	if p := h.pipelines.PipelineFromName(name); p == nil {
+	p := h.pipelines.PipelineFromName(name)
+	if p == nil {
 		slog.Error("Could not find pipeline", "name", name)
-		// todo
-	} else {
-		h.page.Execute(w, *p)
+		http.NotFound(w, r)
+		return
+	}
+
+	switch {
+	case rest == "":
+		h.page.Execute(w, pipelinePageData{Pipeline: p})
+	case rest == "runs":
+		offset, limit, err := pageRuns(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		runs, err := h.runs.Runs(name, offset, limit)
+		if err != nil {
+			slog.Error("Could not list runs", "pipeline", name, "error", err)
+			http.Error(w, "could not list runs", http.StatusInternalServerError)
+			return
+		}
+
+		data := runsPageData{Name: name, Runs: runs, Newer: -1, Older: -1}
+		if offset > 0 {
+			data.Newer = max(offset-limit, 0)
+		}
+		if len(runs) == limit {
+			data.Older = offset + limit
+		}
+
+		h.runsPage.Execute(w, data)
+	case strings.HasPrefix(rest, "runs/"):
+		id := strings.TrimPrefix(rest, "runs/")
+		run, err := h.runs.Run(id)
+		switch {
+		case errors.Is(err, history.ErrRunNotFound) || err == nil && run.Pipeline != name:
+			slog.Error("Could not find run", "pipeline", name, "id", id)
+			http.NotFound(w, r)
+			return
+		case err != nil:
+			slog.Error("Could not read run", "pipeline", name, "id", id, "error", err)
+			http.Error(w, "could not read run", http.StatusInternalServerError)
+			return
+		}
+
+		h.page.Execute(w, pipelinePageData{Pipeline: p, RunID: id})
+	default:
+		slog.Error("Unknown pipeline page", "path", r.URL.Path)
+		http.NotFound(w, r)
 	}
 }
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"log/slog"
+	"net/http"
+
+	server "executrix/server/state"
+)
+
+// ReloadHandler reloads the pipeline files on POST /api/reload, without
+// waiting for the pipeline directory to be checked for changes. It answers
+// like POST /api/v1/reload.
+type ReloadHandler struct {
+	state server.IServerState
+}
+
+func NewReloadHandler(state server.IServerState) ReloadHandler {
+	return ReloadHandler{
+		state: state,
+	}
+}
+
+func (h ReloadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Request to reload pipelines")
+	slog.Debug("Request to reload pipelines", "request", *r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeAPIError(w, http.StatusMethodNotAllowed, codeMethodNotAllowed, "method "+r.Method+" is not allowed, use POST")
+		return
+	}
+
+	reloadPipelines(w, h.state)
+}
@@ -0,0 +1,58 @@
+package routes
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"executrix/executrix"
+	"executrix/history"
+	"executrix/output"
+	"executrix/pipeline"
+)
+
+type statusResponse struct {
+	Running    bool                 `json:"running"`
+	StepStates []pipeline.StateInfo `json:"stepStates"`
+}
+
+type outputResponse struct {
+	Text  string        `json:"text"`
+	Lines []output.Line `json:"lines"`
+}
+
+// outputError creates an output response showing the error message as text.
+func outputError(message string) outputResponse {
+	return outputResponse{Text: message, Lines: []output.Line{}}
+}
+
+type triggerResponse struct {
+	Started bool     `json:"started"`
+	Queued  bool     `json:"queued,omitempty"`
+	RunID   string   `json:"runId,omitempty"`
+	Plan    []string `json:"plan,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+type successResponse struct {
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// writeJSON encodes the response as JSON, so that all text, e.g. the output
+// of a step, reaches the client exactly as it is.
+func writeJSON(w http.ResponseWriter, response any) {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Could not write response", "error", err)
+	}
+}
+
+// queueSummaries returns the run summaries of queued executions.
+func queueSummaries(queue []*executrix.Execution) []history.Run {
+	runs := []history.Run{}
+	for _, exec := range queue {
+		runs = append(runs, exec.Record().Summary())
+	}
+
+	return runs
+}
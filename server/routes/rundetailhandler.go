@@ -0,0 +1,65 @@
+package routes
+
+import (
+	"errors"
+	"executrix/history"
+	"executrix/output"
+	server "executrix/server/state"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// RunDetailHandler serves a single run by its ID, either as summary under
+// /run/{id} or the output of one of its steps under /run/{id}/output/{step}.
+type RunDetailHandler struct {
+	state server.IServerState
+}
+
+func NewRunDetailHandler(state server.IServerState) RunDetailHandler {
+	return RunDetailHandler{
+		state: state,
+	}
+}
+
+func (h RunDetailHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Request to run endpoint")
+	slog.Debug("Request to run endpoint", "request", *r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	id, stepName, isOutput := strings.Cut(strings.TrimPrefix(r.URL.Path, "/run/"), "/output/")
+
+	run, err := h.state.Run(id)
+	if errors.Is(err, history.ErrRunNotFound) {
+		slog.Error("Could not find run", "id", id)
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.Error("Could not read run", "id", id, "error", err)
+		http.Error(w, "could not read run", http.StatusInternalServerError)
+		return
+	}
+
+	if !isOutput {
+		writeJSON(w, run.Summary())
+		return
+	}
+
+	log, err := run.StepOutput(stepName)
+	if err != nil {
+		slog.Error("Error retrieving step output", "run", id, "step", stepName)
+		writeJSON(w, outputError("Error retrieving step output!"))
+		return
+	}
+
+	lines, err := pageOutput(log, r.URL.Query())
+	if err != nil {
+		slog.Error("Invalid output page", "run", id, "step", stepName, "error", err)
+		writeJSON(w, outputError("Error retrieving step output: "+err.Error()))
+		return
+	}
+
+	writeJSON(w, outputResponse{Text: output.Text(lines), Lines: lines})
+}
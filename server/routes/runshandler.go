@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"errors"
+	server "executrix/server/state"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// runsPageSize is the number of runs listed if a request does not set a
+// limit.
+const runsPageSize = 100
+
+type RunsHandler struct {
+	state server.IServerState
+}
+
+func NewRunsHandler(state server.IServerState) RunsHandler {
+	return RunsHandler{
+		state: state,
+	}
+}
+
+func (h RunsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Request to runs endpoint")
+	slog.Debug("Request to runs endpoint", "request", *r)
+
+	w.Header().Set("Content-Type", "application/json")
+
+	name := strings.TrimPrefix(r.URL.Path, "/runs/")
+	if h.state.PipelineFromName(name) == nil {
+		slog.Error("Could not find pipeline", "name", name)
+		http.NotFound(w, r)
+		return
+	}
+
+	offset, limit, err := pageRuns(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	runs, err := h.state.Runs(name, offset, limit)
+	if err != nil {
+		slog.Error("Could not list runs", "pipeline", name, "error", err)
+		http.Error(w, "could not list runs", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, runs)
+}
+
+// pageRuns reads the offset and limit of a list of runs from the query.
+func pageRuns(query url.Values) (offset int, limit int, err error) {
+	limit = runsPageSize
+
+	if val := query.Get("offset"); val != "" {
+		offset, err = strconv.Atoi(val)
+		if err != nil || offset < 0 {
+			return 0, 0, errors.New("offset has to be a non-negative number")
+		}
+	}
+
+	if val := query.Get("limit"); val != "" {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit <= 0 {
+			return 0, 0, errors.New("limit has to be a positive number")
+		}
+	}
+
+	return offset, limit, nil
+}
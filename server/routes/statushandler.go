@@ -1,12 +1,9 @@
 package routes
 
 import (
-	"encoding/json"
 	server "executrix/server/state"
-	"fmt"
 	"log/slog"
 	"net/http"
-	"strconv"
 	"strings"
 )
 
@@ -30,19 +27,17 @@ func (h StatusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	pipeline := h.state.PipelineFromName(name)
 	if pipeline == nil {
 		slog.Error("Could not find pipeline", "name", name)
-		fmt.Fprint(w, `{"running": false}`) // todo error handling
+		http.NotFound(w, r)
 		return
 	}
 
-	bytes, err := json.Marshal(pipeline.GetStepStates())
-	if err != nil {
-		slog.Error("Could not create status data")
-		fmt.Fprint(w, `{"running": false}`) // todo error handling
-		return
+	states := pipeline.GetStepStates()
+	if exec := h.state.Execution(name); exec != nil {
+		states = exec.StepStates()
 	}
 
-	fmt.Fprint(w, "{"+
-		`"running": `+strconv.FormatBool(h.state.IsRunning())+", "+
-		`"stepStates": `+string(bytes)+
-		"}")
+	writeJSON(w, statusResponse{
+		Running:    h.state.IsRunning(name),
+		StepStates: states,
+	})
 }
@@ -0,0 +1,202 @@
+package routes
+
+import (
+	"encoding/json"
+	"executrix/executrix"
+	"executrix/output"
+	"executrix/pipeline"
+	server "executrix/server/state"
+	"executrix/step"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const streamInterval = 250 * time.Millisecond
+
+// StreamHandler pushes the step states and output lines of the current
+// execution of a pipeline as server-sent events until the execution has
+// finished.
+//
+// Every event carries the number of output lines sent so far per step as its
+// ID. A client resumes a stream by passing the ID of the last event it
+// received, either in the Last-Event-ID header or the lastEventId parameter.
+type StreamHandler struct {
+	state server.IServerState
+}
+
+type stateEvent struct {
+	Step    string     `json:"step"`
+	State   step.State `json:"state"`
+	Attempt int        `json:"attempt,omitempty"`
+}
+
+type outputEvent struct {
+	Step   string        `json:"step"`
+	Line   int           `json:"line"`
+	Stream output.Stream `json:"stream"`
+	Text   string        `json:"text"`
+}
+
+type endEvent struct {
+	RunID  string `json:"runId"`  // empty if there is no execution to follow
+	Queued int    `json:"queued"` // number of runs waiting to be started
+}
+
+func NewStreamHandler(state server.IServerState) StreamHandler {
+	return StreamHandler{
+		state: state,
+	}
+}
+
+func (h StreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	slog.Info("Request to stream endpoint")
+	slog.Debug("Request to stream endpoint", "request", *r)
+
+	name := strings.TrimPrefix(r.URL.Path, "/stream/")
+	p := h.state.PipelineFromName(name)
+	if p == nil {
+		slog.Error("Could not find pipeline", "name", name)
+		http.NotFound(w, r)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		slog.Error("Streaming not supported by response writer")
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	exec := h.state.Execution(name)
+	if exec == nil {
+		for _, s := range p.GetStepStates() {
+			writeEvent(w, "", "state", stateEvent{Step: s.Step, State: s.State})
+		}
+		writeEvent(w, "", "queue", queueSummaries(h.state.Queue(name)))
+		writeEvent(w, "", "end", endEvent{Queued: len(h.state.Queue(name))})
+		flusher.Flush()
+		return
+	}
+
+	lastEventID := r.Header.Get("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("lastEventId")
+	}
+	offsets := parseOffsets(lastEventID, exec.ID())
+
+	// states and the queue are always sent in full after (re-)connecting
+	states := map[string]pipeline.StateInfo{}
+	var queue []string
+
+	ticker := time.NewTicker(streamInterval)
+	defer ticker.Stop()
+
+	for {
+		// check before sending, so that nothing written before finishing is missed
+		finished := exec.IsFinished()
+
+		for _, s := range exec.StepStates() {
+			if last, ok := states[s.Step]; !ok || last != s {
+				states[s.Step] = s
+				writeEvent(w, formatOffsets(exec.ID(), offsets), "state", stateEvent{Step: s.Step, State: s.State, Attempt: s.Attempt})
+			}
+		}
+
+		if ids := queuedIDs(h.state.Queue(name)); queue == nil || !slices.Equal(ids, queue) {
+			queue = ids
+			writeEvent(w, formatOffsets(exec.ID(), offsets), "queue", queueSummaries(h.state.Queue(name)))
+		}
+
+		for _, s := range exec.Plan() {
+			log, err := exec.StepOutput(s)
+			if err != nil {
+				// the step has not been started yet
+				continue
+			}
+
+			for _, line := range log.Since(offsets[s]) {
+				offsets[s] = line.Seq + 1
+				writeEvent(w, formatOffsets(exec.ID(), offsets), "output", outputEvent{Step: s, Line: line.Seq, Stream: line.Stream, Text: line.Text})
+			}
+		}
+
+		if finished {
+			writeEvent(w, formatOffsets(exec.ID(), offsets), "end", endEvent{RunID: exec.ID(), Queued: len(queue)})
+			flusher.Flush()
+			return
+		}
+
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			slog.Debug("Stream closed by client", "pipeline", name)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func queuedIDs(queue []*executrix.Execution) []string {
+	ids := []string{}
+	for _, exec := range queue {
+		ids = append(ids, exec.ID())
+	}
+
+	return ids
+}
+
+func writeEvent(w http.ResponseWriter, id string, event string, data any) {
+	bytes, err := json.Marshal(data)
+	if err != nil {
+		slog.Error("Could not create event data", "event", event, "error", err)
+		return
+	}
+
+	if id != "" {
+		fmt.Fprintf(w, "id: %s\n", id)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, bytes)
+}
+
+// formatOffsets encodes the run ID and the number of output lines sent per
+// step as event ID.
+func formatOffsets(runID string, offsets map[string]int) string {
+	values := url.Values{}
+	values.Set("run", runID)
+	for s, n := range offsets {
+		values.Set("step:"+s, strconv.Itoa(n))
+	}
+
+	return values.Encode()
+}
+
+// parseOffsets decodes an event ID created by formatOffsets. Offsets of a
+// different run are ignored.
+func parseOffsets(id string, runID string) map[string]int {
+	offsets := map[string]int{}
+
+	values, err := url.ParseQuery(id)
+	if err != nil || values.Get("run") != runID {
+		return offsets
+	}
+
+	for key := range values {
+		if s, ok := strings.CutPrefix(key, "step:"); ok {
+			if n, err := strconv.Atoi(values.Get(key)); err == nil && n >= 0 {
+				offsets[s] = n
+			}
+		}
+	}
+
+	return offsets
+}
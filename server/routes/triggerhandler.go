@@ -1,16 +1,26 @@
 package routes
 
 import (
+	"bytes"
 	"encoding/json"
 	"executrix/data"
 	server "executrix/server/state"
 	"fmt"
 	"io"
 	"log/slog"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
+// triggerRequest is the body of a trigger request. A plain list of steps is
+// accepted as well, then the defaults of all parameters are used.
+type triggerRequest struct {
+	Steps      []data.StepInfo
+	Parameters map[string]any
+}
+
 type TriggerHandler struct {
 	state server.IServerState
 }
@@ -25,48 +35,81 @@ func (h TriggerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Request to trigger endpoint")
 	slog.Debug("Request to trigger endpoint", "request", *r)
 
-	if h.state.HasExecution() {
-		// todo queueing?
-		slog.Error("Already running a pipeline")
-		fmt.Fprint(w, `{"started": false}`) // todo give reason
-		return
-	}
-
 	w.Header().Set("Content-Type", "application/json")
 
 	name := strings.TrimPrefix(r.URL.Path, "/trigger/")
 	pipeline := h.state.PipelineFromName(name)
 	if pipeline == nil {
 		slog.Error("Could not find pipeline", "name", name)
-		fmt.Fprint(w, `{"started": false}`) // todo give reason
+		writeJSON(w, triggerResponse{Error: "unknown pipeline: " + name})
 		return
 	}
 
 	body, err := io.ReadAll(r.Body)
 	if err != nil {
 		slog.Error("Could not read body from request", "err", err)
-		fmt.Fprint(w, `{"started": false}`) // todo give reason
+		writeJSON(w, triggerResponse{Error: "could not read request body"})
 		return
 	}
 
 	slog.Debug("recieved body", "body", body)
 
-	var stepInfo []data.StepInfo
-	if err = json.Unmarshal(body, &stepInfo); err != nil {
+	var request triggerRequest
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(body, &request.Steps)
+	} else {
+		err = json.Unmarshal(body, &request)
+	}
+	if err != nil {
 		slog.Error("Could not unmarshall body from request", "err", err)
-		fmt.Fprint(w, `{"started": false}`) // todo give reason
+		writeJSON(w, triggerResponse{Error: "invalid request body: " + err.Error()})
 		return
 	}
 
-	slog.Debug("parsed body", "body", stepInfo)
+	slog.Debug("parsed body", "body", request)
+
+	params, err := parameterValues(request.Parameters)
+	if err != nil {
+		writeJSON(w, triggerResponse{Error: err.Error()})
+		return
+	}
 
-	if err := h.state.NewExecution(pipeline, stepInfo); err != nil {
+	exec, queued, err := h.state.Trigger(pipeline, request.Steps, params, triggeredBy(r))
+	if err != nil {
 		slog.Error("Could not create new execution", "err", err)
-		fmt.Fprint(w, `{"started": false}`) // todo give reason
+		writeJSON(w, triggerResponse{Error: err.Error()})
 		return
 	}
 
-	go h.state.Execute()
+	writeJSON(w, triggerResponse{Started: !queued, Queued: queued, RunID: exec.ID(), Plan: exec.Plan()})
+}
+
+// triggeredBy describes the sender of a request for the run history.
+func triggeredBy(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return "web: " + host
+}
+
+// parameterValues converts the parameter values of a trigger request, which
+// can be given as JSON strings, booleans or numbers.
+func parameterValues(values map[string]any) (map[string]string, error) {
+	params := make(map[string]string)
+	for name, val := range values {
+		switch v := val.(type) {
+		case string:
+			params[name] = v
+		case bool:
+			params[name] = strconv.FormatBool(v)
+		case float64:
+			params[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			return nil, fmt.Errorf("unexpected type for value of parameter %q", name)
+		}
+	}
 
-	fmt.Fprint(w, `{"started": true}`)
+	return params, nil
 }
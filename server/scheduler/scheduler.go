@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"executrix/data"
+	"executrix/pipeline"
+	"executrix/schedule"
+	server "executrix/server/state"
+)
+
+// Scheduler triggers pipelines with a schedule at the planned times. The
+// triggers are handled like manual ones, so the queue policy of the pipeline
+// applies.
+type Scheduler struct {
+	state server.IServerState
+	mu    sync.Mutex // serializes starting and stopping
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewScheduler(state server.IServerState) *Scheduler {
+	return &Scheduler{
+		state: state,
+	}
+}
+
+// Start plans the next run of every scheduled pipeline in the background.
+func (s *Scheduler) Start() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.start()
+}
+
+// Stop cancels all planned runs and waits until the scheduler has stopped.
+// Executions started by the scheduler are not affected.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.halt()
+}
+
+// Restart plans the runs anew from the current pipeline definitions, e.g.
+// after the pipelines have been reloaded.
+func (s *Scheduler) Restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	slog.Info("Restarting scheduler")
+	s.halt()
+	s.start()
+}
+
+func (s *Scheduler) start() {
+	s.stop = make(chan struct{})
+
+	for _, p := range s.state.AllPipelines() {
+		if p.Schedule == nil {
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.run(p)
+	}
+}
+
+func (s *Scheduler) halt() {
+	close(s.stop)
+	s.wg.Wait()
+}
+
+func (s *Scheduler) run(p *pipeline.Pipeline) {
+	defer s.wg.Done()
+
+	for {
+		next, cron := p.Schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("No further scheduled runs", "pipeline", p.Name)
+			return
+		}
+
+		slog.Info("Planned scheduled run", "pipeline", p.Name, "at", next, "cron", cron.String())
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-s.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.trigger(p, cron)
+	}
+}
+
+func (s *Scheduler) trigger(p *pipeline.Pipeline, cron schedule.Cron) {
+	var stepInfo []data.StepInfo
+	for _, name := range p.ScheduledSteps() {
+		stepInfo = append(stepInfo, data.StepInfo{StepName: name, Checked: true})
+	}
+
+	trigger := "scheduler: " + cron.String()
+
+	exec, queued, err := s.state.Trigger(p, stepInfo, p.Schedule.Parameters, trigger)
+	if errors.Is(err, server.ErrNotReset) {
+		// the results of the previous run are kept in the history, they must
+		// not prevent the next scheduled run
+		slog.Info("Resetting finished execution for scheduled run", "pipeline", p.Name)
+		if err = s.state.Reset(p.Name); err == nil {
+			exec, queued, err = s.state.Trigger(p, stepInfo, p.Schedule.Parameters, trigger)
+		}
+	}
+
+	if err != nil {
+		slog.Error("Failed to trigger scheduled run", "pipeline", p.Name, "error", err)
+		return
+	}
+
+	slog.Info("Triggered scheduled run", "pipeline", p.Name, "run", exec.ID(), "queued", queued)
+}
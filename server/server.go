@@ -8,17 +8,21 @@ import (
 	"text/template"
 
 	"executrix/constants"
+	"executrix/history"
 	"executrix/server/config"
 	"executrix/server/routes"
+	"executrix/server/scheduler"
 	"executrix/server/state"
 )
 
 type Server struct {
 	serverConfig config.ServerConfig
 	globalConfig config.GlobalConfig
-	state        state.ServerState
+	state        *state.ServerState
+	scheduler    *scheduler.Scheduler
 	indexPage    template.Template
 	pipelinePage template.Template
+	runsPage     template.Template
 }
 
 func NewServer(serverConfig config.ServerConfig) (Server, error) {
@@ -44,8 +48,35 @@ func NewServer(serverConfig config.ServerConfig) (Server, error) {
 		return Server{}, err
 	}
 
+	runsTemplate, err := template.ParseFiles("html/runs.html")
+	if err != nil {
+		slog.Error("Failed to parse runs.html", "error", err)
+		return Server{}, err
+	}
+
+	// the run history is kept in the output dir, relative paths are based on the config dir
+	outputDir := globalConfig.GetOutputDir()
+	if outputDir == "" {
+		outputDir = constants.RUNS_DIR_NAME
+	}
+	if !filepath.IsAbs(outputDir) {
+		outputDir = filepath.Join(serverConfig.GetConfigDir(), outputDir)
+	}
+
+	runs, err := history.NewStore(outputDir)
+	if err != nil {
+		slog.Error("Failed to open run history", "error", err)
+		return Server{}, err
+	}
+
+	secrets, err := config.SecretsFromJson(filepath.Join(serverConfig.GetConfigDir(), constants.SECRETS_FILE))
+	if err != nil {
+		slog.Error("Failed to load secrets", "error", err)
+		return Server{}, err
+	}
+
 	// creating struct for tracking the state of the server
-	state, err := state.NewServerState(serverConfig.GetPipelineDir(), globalConfig)
+	state, err := state.NewServerState(serverConfig.GetPipelineDir(), globalConfig, secrets, runs)
 	if err != nil {
 		slog.Error("Failed to read pipeline configs", "error", err)
 		return Server{}, err
@@ -55,8 +86,10 @@ func NewServer(serverConfig config.ServerConfig) (Server, error) {
 		serverConfig: serverConfig,
 		globalConfig: globalConfig,
 		state:        state,
+		scheduler:    scheduler.NewScheduler(state),
 		indexPage:    *indexTemplate,
 		pipelinePage: *pipelineTemplate,
+		runsPage:     *runsTemplate,
 	}, nil
 }
 
@@ -64,12 +97,17 @@ func (s *Server) Serve() error {
 	mux := http.NewServeMux()
 
 	indexHandler := routes.NewIndexHandler(s.indexPage, s.state)
-	pipelineHandler := routes.NewPipelineHandler(s.pipelinePage, &s.state)
-	triggerHandler := routes.NewTriggerHandler(&s.state)
-	statusHandler := routes.NewStatusHandler(&s.state)
-	outputHandler := routes.NewOutputHandler(&s.state)
-	newRunHandler := routes.NewNewRunHandler(&s.state)
-	newKillHandler := routes.NewKillHandler(&s.state)
+	pipelineHandler := routes.NewPipelineHandler(s.pipelinePage, s.runsPage, s.state, s.state)
+	triggerHandler := routes.NewTriggerHandler(s.state)
+	statusHandler := routes.NewStatusHandler(s.state)
+	outputHandler := routes.NewOutputHandler(s.state)
+	newRunHandler := routes.NewNewRunHandler(s.state)
+	newKillHandler := routes.NewKillHandler(s.state)
+	runsHandler := routes.NewRunsHandler(s.state)
+	runDetailHandler := routes.NewRunDetailHandler(s.state)
+	streamHandler := routes.NewStreamHandler(s.state)
+	apiHandler := routes.NewAPIHandler(s.state)
+	reloadHandler := routes.NewReloadHandler(s.state)
 
 	mux.Handle("/", indexHandler)
 	mux.Handle("/pipeline/", pipelineHandler)
@@ -78,6 +116,21 @@ func (s *Server) Serve() error {
 	mux.Handle("/output/", outputHandler)
 	mux.Handle("/new/", newRunHandler)
 	mux.Handle("/kill/", newKillHandler)
+	mux.Handle("/runs/", runsHandler)
+	mux.Handle("/run/", runDetailHandler)
+	mux.Handle("/stream/", streamHandler)
+	mux.Handle("/api/v1/", apiHandler)
+	mux.Handle("/api/reload", reloadHandler)
+	mux.Handle("/schema/", http.StripPrefix("/schema/", http.FileServer(http.Dir("schema"))))
+
+	// scheduled runs are planned anew with the reloaded pipelines
+	s.state.OnReload(s.scheduler.Restart)
+	s.scheduler.Start()
+	defer s.scheduler.Stop()
+
+	stopWatching := make(chan struct{})
+	go s.state.Watch(constants.PIPELINE_POLL_INTERVAL, stopWatching)
+	defer close(stopWatching)
 
 	slog.Info("Start listening", "port", s.serverConfig.GetPort())
 	if err := http.ListenAndServe(fmt.Sprintf("localhost:%d", s.serverConfig.GetPort()), mux); err != nil {
@@ -0,0 +1,228 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"executrix/helper"
+	"executrix/pipeline"
+	"executrix/server/config"
+)
+
+// ReloadResult describes the pipelines after a reload.
+type ReloadResult struct {
+	Pipelines []string             `json:"pipelines"` // names of all loaded pipelines
+	Deferred  []string             `json:"deferred"`  // busy pipelines keeping their old definition until their runs have finished
+	Errors    []pipeline.LoadError `json:"errors"`    // of the files which could not be loaded
+}
+
+// LoadErrors returns why pipeline files could not be loaded at the last
+// reload, and the warnings of the loaded ones.
+func (s *ServerState) LoadErrors() []pipeline.LoadError {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.loadErrors)
+}
+
+// OnReload registers f to be called whenever the pipeline definitions have
+// changed, e.g. to plan the scheduled runs anew.
+func (s *ServerState) OnReload(f func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.onReload = append(s.onReload, f)
+}
+
+// Reload reads all pipeline files again and replaces the pipelines at once.
+// A pipeline which is running or has queued runs keeps its old definition
+// until its runs have finished, so that a run is never changed while it is
+// performed. Files which cannot be loaded are skipped and reported as
+// errors.
+func (s *ServerState) Reload() (ReloadResult, error) {
+	// taken before reading, so that changes made meanwhile cause another reload
+	fingerprint, err := fingerprintFiles(s.pipelineDir)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	loaded, loadErrors, err := loadPipelines(s.pipelineDir, s.cfg, s.secrets)
+	if err != nil {
+		return ReloadResult{}, err
+	}
+
+	s.mu.Lock()
+
+	result := ReloadResult{Pipelines: []string{}, Deferred: []string{}, Errors: loadErrors}
+	next := make([]*pipeline.Pipeline, 0, len(loaded))
+	clear(s.pending)
+
+	for _, p := range loaded {
+		result.Pipelines = append(result.Pipelines, p.Name)
+
+		if old := s.pipelineFromName(p.Name); old != nil && s.isBusy(p.Name) {
+			slog.Info("Deferring reload of busy pipeline", "pipeline", p.Name)
+			s.pending[p.Name] = p
+			result.Deferred = append(result.Deferred, p.Name)
+			next = append(next, old)
+			continue
+		}
+
+		next = append(next, p)
+	}
+
+	// removed pipelines are kept as well until their runs have finished
+	for _, old := range s.pipelines {
+		removed := !slices.ContainsFunc(loaded, func(p *pipeline.Pipeline) bool { return p.Name == old.Name })
+		if removed && s.isBusy(old.Name) {
+			slog.Info("Deferring removal of busy pipeline", "pipeline", old.Name)
+			s.pending[old.Name] = nil
+			result.Deferred = append(result.Deferred, old.Name)
+			next = append(next, old)
+		}
+	}
+
+	s.pipelines = next
+	s.loadErrors = loadErrors
+	s.fingerprint = fingerprint
+	s.mu.Unlock()
+
+	slog.Info("Reloaded pipelines", "pipelines", result.Pipelines, "deferred", result.Deferred, "errors", len(result.Errors))
+	s.notifyReload()
+
+	return result, nil
+}
+
+// Watch reloads the pipelines whenever the files in the pipeline directory
+// change, until stop is closed. The directory is polled at the given interval.
+func (s *ServerState) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		fingerprint, err := fingerprintFiles(s.pipelineDir)
+		if err != nil {
+			slog.Error("Failed to check pipeline files for changes", "error", err)
+			continue
+		}
+
+		s.mu.Lock()
+		changed := fingerprint != s.fingerprint
+		s.mu.Unlock()
+
+		if !changed {
+			continue
+		}
+
+		slog.Info("Pipeline files changed, reloading", "dir", s.pipelineDir)
+		if _, err := s.Reload(); err != nil {
+			slog.Error("Failed to reload pipelines", "error", err)
+		}
+	}
+}
+
+// isBusy reports whether the pipeline is running or has queued runs. s.mu
+// has to be held by the caller.
+func (s *ServerState) isBusy(name string) bool {
+	exec, ok := s.executions[name]
+	return ok && !exec.IsFinished() || len(s.queues[name]) > 0
+}
+
+// applyPending replaces the pipeline with the definition read while it was
+// busy, and reports whether there was one. s.mu has to be held by the caller.
+func (s *ServerState) applyPending(name string) bool {
+	next, ok := s.pending[name]
+	if !ok {
+		return false
+	}
+	delete(s.pending, name)
+
+	idx := slices.IndexFunc(s.pipelines, func(p *pipeline.Pipeline) bool { return p.Name == name })
+	switch {
+	case idx < 0:
+		return false
+	case next == nil:
+		slog.Info("Removing pipeline after its runs have finished", "pipeline", name)
+		s.pipelines = slices.Delete(s.pipelines, idx, idx+1)
+		delete(s.executions, name)
+	default:
+		slog.Info("Applying reloaded pipeline after its runs have finished", "pipeline", name)
+		s.pipelines[idx] = next
+	}
+
+	return true
+}
+
+func (s *ServerState) notifyReload() {
+	s.mu.Lock()
+	callbacks := slices.Clone(s.onReload)
+	s.mu.Unlock()
+
+	for _, f := range callbacks {
+		f()
+	}
+}
+
+func loadPipelines(pipelineDir string, cfg config.GlobalConfig, secrets config.Secrets) ([]*pipeline.Pipeline, []pipeline.LoadError, error) {
+	result, err := helper.FindAllFiles(pipelineDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var pipelines []*pipeline.Pipeline
+	loadErrors := []pipeline.LoadError{}
+	for _, file := range result {
+		p, warnings, err := pipeline.PipelineFromJson(file, cfg, secrets.Names())
+		if err != nil {
+			slog.Error("Error reading pipline configuration", "file", file, "error", err)
+
+			var fileErrors pipeline.LoadErrors
+			if !errors.As(err, &fileErrors) {
+				fileErrors = pipeline.LoadErrors{{File: file, Reason: err.Error()}}
+			}
+			loadErrors = append(loadErrors, fileErrors...)
+			continue
+		}
+
+		for _, warning := range warnings {
+			slog.Warn("Problem in pipline configuration", "file", file, "warning", warning.Error())
+		}
+		loadErrors = append(loadErrors, warnings...)
+
+		pipelines = append(pipelines, &p)
+	}
+
+	return pipelines, loadErrors, nil
+}
+
+// fingerprintFiles describes the names, sizes and modification times of the
+// files in dir, so that changes are noticed without reading the files.
+func fingerprintFiles(dir string) (string, error) {
+	files, err := helper.FindAllFiles(dir)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			// removed meanwhile, noticed on the next check
+			continue
+		}
+		fmt.Fprintf(&b, "%s %d %d\n", file, info.Size(), info.ModTime().UnixNano())
+	}
+
+	return b.String(), nil
+}
@@ -4,135 +4,353 @@ import (
 	"errors"
 	"log/slog"
 	"slices"
+	"sync"
 
 	"executrix/data"
 	"executrix/executrix"
-	"executrix/helper"
+	"executrix/history"
+	"executrix/output"
 	"executrix/pipeline"
 	"executrix/server/config"
 )
 
+var (
+	ErrPipelineNotFound = errors.New("pipeline not found")
+	ErrAlreadyRunning   = errors.New("an execution is already in progress")
+	ErrNotReset         = errors.New("the previous execution has not been reset")
+	ErrNoExecution      = errors.New("no performing or performed execution")
+	ErrQueueFull        = errors.New("the queue of the pipeline is full")
+	ErrNotQueued        = errors.New("run is not queued")
+)
+
 type IPipelineContainer interface {
 	PipelineFromName(name string) *pipeline.Pipeline
 }
 
+type IRunHistory interface {
+	Runs(pipeline string, offset int, limit int) ([]history.Run, error)
+	Run(id string) (history.Run, error)
+}
+
 type IServerState interface {
 	IPipelineContainer
-	HasExecution() bool
-	IsRunning() bool
-	StepOutput(name string) (string, error)
-	NewExecution(p *pipeline.Pipeline, stepInfo []data.StepInfo) error
-	Execute()
+	IRunHistory
+	AllPipelines() []*pipeline.Pipeline
+	HasExecution(pipeline string) bool
+	Execution(pipeline string) *executrix.Execution
+	IsRunning(pipeline string) bool
+	StepOutput(pipeline string, step string) (*output.Log, error)
+	Trigger(p *pipeline.Pipeline, stepInfo []data.StepInfo, params map[string]string, trigger string) (exec *executrix.Execution, queued bool, err error)
+	Queue(pipeline string) []*executrix.Execution
+	CancelQueued(pipeline string, id string) error
 	Reset(pipeline string) error
 	Kill(pipelin string) error
+	Reload() (ReloadResult, error)
+	LoadErrors() []pipeline.LoadError
 }
 
 type ServerState struct {
-	Pipelines []pipeline.Pipeline
-	execution *executrix.Execution
+	pipelines   []*pipeline.Pipeline
+	pending     map[string]*pipeline.Pipeline // reloaded definitions of busy pipelines, nil if removed
+	loadErrors  []pipeline.LoadError          // of the pipeline files at the last reload
+	pipelineDir string
+	cfg         config.GlobalConfig
+	fingerprint string   // of the pipeline files at the last reload
+	onReload    []func() // called after the pipelines have changed
+	mu          sync.Mutex
+	executions  map[string]*executrix.Execution   // current execution per pipeline
+	queues      map[string][]*executrix.Execution // executions waiting to be started per pipeline
+	history     history.Store
+	secrets     config.Secrets
 }
 
-func NewServerState(pipelineDir string, cfg config.GlobalConfig) (ServerState, error) {
-	state := ServerState{}
+func NewServerState(pipelineDir string, cfg config.GlobalConfig, secrets config.Secrets, runs history.Store) (*ServerState, error) {
+	state := &ServerState{
+		pending:     make(map[string]*pipeline.Pipeline),
+		pipelineDir: pipelineDir,
+		cfg:         cfg,
+		executions:  make(map[string]*executrix.Execution),
+		queues:      make(map[string][]*executrix.Execution),
+		history:     runs,
+		secrets:     secrets,
+	}
 
-	if err := state.reloadPipelines(pipelineDir, cfg); err != nil {
+	if _, err := state.Reload(); err != nil {
 		slog.Error("Error while reloading pipeline configs", "err", err)
-		return ServerState{}, errors.New("error loading pipeline configs")
+		return nil, errors.New("error loading pipeline configs")
 	}
 
 	return state, nil
 }
 
+// PipelineFromName returns the current definition of the pipeline, or nil if
+// there is none. Executions keep the definition they were started with.
 func (s *ServerState) PipelineFromName(name string) *pipeline.Pipeline {
-	if idx := slices.IndexFunc(s.Pipelines, func(p pipeline.Pipeline) bool { return p.Name == name }); idx < 0 {
-		return nil
-	} else {
-		return &s.Pipelines[idx]
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.pipelineFromName(name)
+}
+
+// pipelineFromName is PipelineFromName for callers holding s.mu.
+func (s *ServerState) pipelineFromName(name string) *pipeline.Pipeline {
+	if idx := slices.IndexFunc(s.pipelines, func(p *pipeline.Pipeline) bool { return p.Name == name }); idx >= 0 {
+		return s.pipelines[idx]
 	}
+
+	return nil
 }
 
-func (s *ServerState) IsRunning() bool {
-	return s.execution != nil && !s.execution.IsFinished()
+func (s *ServerState) AllPipelines() []*pipeline.Pipeline {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.pipelines)
+}
+
+func (s *ServerState) IsRunning(pipeline string) bool {
+	exec := s.Execution(pipeline)
+	return exec != nil && !exec.IsFinished()
+}
+
+func (s *ServerState) HasExecution(pipeline string) bool {
+	return s.Execution(pipeline) != nil
 }
 
-func (s *ServerState) HasExecution() bool {
-	return s.execution != nil
+// Execution returns the current execution of the pipeline, or nil if there
+// is none. Executions are replaced by Trigger and Reset from other goroutines.
+func (s *ServerState) Execution(pipeline string) *executrix.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.executions[pipeline]
 }
 
-func (s *ServerState) StepOutput(step string) (string, error) {
-	if !s.HasExecution() {
-		return "", errors.New("no performing or performed execution")
+func (s *ServerState) StepOutput(pipeline string, step string) (*output.Log, error) {
+	exec := s.Execution(pipeline)
+	if exec == nil {
+		return nil, ErrNoExecution
 	}
 
-	return s.execution.StepOutput(step)
+	return exec.StepOutput(step)
 }
 
-func (s *ServerState) NewExecution(p *pipeline.Pipeline, stepInfo []data.StepInfo) error {
-	exec, err := executrix.NewExecution(p, stepInfo)
+// Trigger creates a new execution of the checked steps and their
+// dependencies with the given parameter values and starts it in the
+// background. Different pipelines can be executed at the same time. While the
+// pipeline is running, the trigger is handled according to the queue policy
+// of the pipeline.
+func (s *ServerState) Trigger(p *pipeline.Pipeline, stepInfo []data.StepInfo, params map[string]string, trigger string) (*executrix.Execution, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, hasCurrent := s.executions[p.Name]
+	running := hasCurrent && !current.IsFinished()
+
+	switch {
+	case running && p.QueuePolicy == pipeline.RejectTriggers:
+		return nil, false, ErrAlreadyRunning
+	case hasCurrent && p.QueuePolicy == pipeline.RejectTriggers:
+		// the results are kept until the execution is reset
+		return nil, false, ErrNotReset
+	case running && p.QueuePolicy == pipeline.QueueTriggers && len(s.queues[p.Name]) >= p.MaxQueueLength:
+		return nil, false, ErrQueueFull
+	}
+
+	exec, err := executrix.NewExecution(p, stepInfo, params, trigger)
 	if err != nil {
-		return errors.New("failed to create new execution")
+		return nil, false, err
 	}
+	exec.SetOutputDir(s.history.Dir())
+	exec.SetSecrets(s.secrets)
 
-	s.execution = exec
+	if !running && len(s.queues[p.Name]) == 0 {
+		s.start(p, exec)
+		return exec, false, nil
+	}
 
-	return nil
+	if p.QueuePolicy == pipeline.ReplaceRunning {
+		slog.Info("Replacing running execution", "pipeline", p.Name, "run", exec.ID())
+		dropped := s.queues[p.Name]
+		s.queues[p.Name] = []*executrix.Execution{exec}
+
+		go func() {
+			// the queued runs are replaced as well, they are kept in the history
+			for _, queued := range dropped {
+				slog.Info("Cancelling replaced queued execution", "pipeline", p.Name, "run", queued.ID(), "replacedBy", exec.ID())
+				queued.Cancel()
+				if err := s.history.Save(queued.Record()); err != nil {
+					slog.Error("Failed to save cancelled run to history", "run", queued.ID(), "error", err)
+				}
+			}
+
+			if running {
+				if err := current.Kill(); err != nil {
+					slog.Error("Failed to kill replaced execution", "run", current.ID(), "error", err)
+				}
+			}
+		}()
+
+		return exec, true, nil
+	}
+
+	slog.Info("Queueing execution", "pipeline", p.Name, "run", exec.ID(), "position", len(s.queues[p.Name]))
+	s.queues[p.Name] = append(s.queues[p.Name], exec)
+
+	return exec, true, nil
+}
+
+// start makes exec the current execution of p and runs it in the background.
+// s.mu has to be held by the caller.
+func (s *ServerState) start(p *pipeline.Pipeline, exec *executrix.Execution) {
+	p.Reset()
+	s.executions[p.Name] = exec
+
+	go s.execute(p, exec)
 }
 
-func (s *ServerState) Execute() {
-	if s.execution == nil {
-		slog.Warn("Trying to call ServerStore::Execute when ServerStore::execution is nil! Ignoring...")
+func (s *ServerState) execute(p *pipeline.Pipeline, exec *executrix.Execution) {
+	exec.Execute()
+
+	exec.SetFinished()
+
+	if err := s.history.Save(exec.Record()); err != nil {
+		slog.Error("Failed to save run to history", "run", exec.ID(), "error", err)
+	}
+
+	s.mu.Lock()
+
+	if queue := s.queues[p.Name]; len(queue) > 0 {
+		slog.Info("Starting queued execution", "pipeline", p.Name, "run", queue[0].ID())
+		s.queues[p.Name] = queue[1:]
+		s.start(p, queue[0])
+		s.mu.Unlock()
 		return
 	}
 
-	s.execution.Execute()
+	swapped := s.applyPending(p.Name)
+	s.mu.Unlock()
 
-	s.execution.SetFinished()
+	if swapped {
+		s.notifyReload()
+	}
 }
 
-func (s *ServerState) Kill(pipeline string) error {
-	if !s.IsRunning() || !s.HasExecution() {
-		slog.Warn("Trying to cancel without running execution")
-		return nil
-	}
+// Queue returns the executions of the pipeline waiting to be started, in the
+// order they will be started.
+func (s *ServerState) Queue(pipeline string) []*executrix.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return s.execution.Kill()
+	return slices.Clone(s.queues[pipeline])
 }
 
-func (s *ServerState) Reset(pipeline string) error {
-	if s.IsRunning() {
-		return errors.New("Trying to call ServerStore::Reset while execution in progress! Ignoring...")
-	}
+func (s *ServerState) CancelQueued(pipeline string, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	p := s.PipelineFromName(pipeline)
-	if p == nil {
-		return errors.New("Pipeline not found")
+	queue := s.queues[pipeline]
+	idx := slices.IndexFunc(queue, func(e *executrix.Execution) bool { return e.ID() == id })
+	if idx < 0 {
+		return ErrNotQueued
 	}
 
-	p.Reset()
-	s.execution = nil
+	slog.Info("Cancelling queued execution", "pipeline", pipeline, "run", id)
+	s.queues[pipeline] = slices.Delete(queue, idx, idx+1)
 
 	return nil
 }
 
-func (s *ServerState) reloadPipelines(pipelineDir string, cfg config.GlobalConfig) error {
-	s.Pipelines = nil
-	slog.Debug("Cleared piplines before reloading")
+// Runs returns the summaries of the runs of a pipeline, newest first,
+// including the current and the queued ones. The first offset runs are
+// skipped and at most limit runs are returned, or all of them if limit is 0.
+func (s *ServerState) Runs(pipeline string, offset int, limit int) ([]history.Run, error) {
+	var runs []history.Run
+
+	// queued runs are started last, so they come first
+	queue := s.Queue(pipeline)
+	for i := len(queue) - 1; i >= 0; i-- {
+		runs = append(runs, queue[i].Record().Summary())
+	}
+
+	// the current run is not stored before it has finished
+	if exec := s.Execution(pipeline); exec != nil && !s.history.Has(pipeline, exec.ID()) {
+		runs = append(runs, exec.Record().Summary())
+	}
+
+	skipped := min(offset, len(runs))
+	runs = runs[skipped:]
+	offset -= skipped
 
-	result, err := helper.FindAllFiles(pipelineDir)
+	if limit > 0 {
+		if len(runs) >= limit {
+			return runs[:limit], nil
+		}
+		limit -= len(runs)
+	}
+
+	stored, err := s.history.List(pipeline, offset, limit)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	return append(runs, stored...), nil
+}
+
+func (s *ServerState) Run(id string) (history.Run, error) {
+	if exec := s.findExecution(id); exec != nil {
+		return exec.Record(), nil
+	}
+
+	return s.history.Load(id)
+}
+
+// findExecution returns the current or queued execution with the given run
+// ID, or nil if there is none.
+func (s *ServerState) findExecution(id string) *executrix.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, exec := range s.executions {
+		if exec.ID() == id {
+			return exec
+		}
 	}
 
-	for _, file := range result {
-		pipeline, err := pipeline.PipelineFromJson(file, cfg)
-		if err != nil {
-			slog.Error("Error reading pipline configuration", "file", file, "error", err)
-			// todo - put info to html?
-			continue
+	for _, queue := range s.queues {
+		if idx := slices.IndexFunc(queue, func(e *executrix.Execution) bool { return e.ID() == id }); idx >= 0 {
+			return queue[idx]
 		}
+	}
+
+	return nil
+}
+
+func (s *ServerState) Kill(pipeline string) error {
+	exec := s.Execution(pipeline)
+	if exec == nil || exec.IsFinished() {
+		slog.Warn("Trying to cancel without running execution", "pipeline", pipeline)
+		return nil
+	}
+
+	return exec.Kill()
+}
+
+func (s *ServerState) Reset(pipeline string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		s.Pipelines = append(s.Pipelines, pipeline)
+	if exec, ok := s.executions[pipeline]; ok && !exec.IsFinished() {
+		return ErrAlreadyRunning
 	}
 
+	p := s.pipelineFromName(pipeline)
+	if p == nil {
+		return ErrPipelineNotFound
+	}
+
+	p.Reset()
+	delete(s.executions, pipeline)
+
 	return nil
 }
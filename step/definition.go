@@ -0,0 +1,131 @@
+package step
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"executrix/helper"
+	"executrix/server/config"
+)
+
+// Definition holds the options of all step types in a pipeline file.
+type Definition struct {
+	Type            string
+	Name            string
+	DependsOn       []string
+	Default         bool // selected when running the default steps
+	ContinueOnError bool
+}
+
+// ScriptDefinition holds the options of the steps running a script.
+type ScriptDefinition struct {
+	Definition
+	ScriptPath   string
+	Arguments    []string
+	Timeout      any // "1h30m" or a number of seconds
+	Retries      int
+	RetryDelay   any // like Timeout
+	RetryBackoff bool
+	Env          map[string]string
+	InheritEnv   *bool // true if not set
+	WorkingDir   string
+}
+
+type PSDefinition struct {
+	ScriptDefinition
+}
+
+type ShellDefinition struct {
+	ScriptDefinition
+	Interpreter string // sh if not set
+	Command     string // instead of ScriptPath
+}
+
+type LinkDefinition struct {
+	Type string
+	Name string
+	Link string
+}
+
+// StepFromJSON reads a step of a pipeline file. The options depend on the
+// type of the step, unknown options are rejected. All invalid options are
+// reported at once.
+func StepFromJSON(data []byte, cfg config.GlobalConfig) (IStep, error) {
+	var head struct{ Type string }
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, err
+	}
+
+	slog.Info("Read step type", "type", head.Type)
+	switch head.Type {
+	case "":
+		return nil, helper.InField("Type", errors.New("could not find step type"))
+	case "PS":
+		var def PSDefinition
+		decodeErr := helper.DecodeStrict(data, &def)
+		step, err := ReadPSType(def, cfg)
+		if err := errors.Join(decodeErr, err); err != nil {
+			return nil, err
+		}
+		return step, nil
+	case "Shell":
+		var def ShellDefinition
+		decodeErr := helper.DecodeStrict(data, &def)
+		step, err := ReadShellType(def, cfg)
+		if err := errors.Join(decodeErr, err); err != nil {
+			return nil, err
+		}
+		return step, nil
+	case "Link":
+		var def LinkDefinition
+		decodeErr := helper.DecodeStrict(data, &def)
+		step, err := ReadLinkType(def, cfg)
+		if err := errors.Join(decodeErr, err); err != nil {
+			return nil, err
+		}
+		return step, nil
+	default:
+		slog.Error("Unknown step type", "type", head.Type)
+		return nil, helper.InField("Type", fmt.Errorf("unknown step type %q", head.Type))
+	}
+}
+
+// scriptOptions are read from a ScriptDefinition for all script steps.
+type scriptOptions struct {
+	timeout time.Duration
+	retry   Retry
+	env     environment
+}
+
+// readScriptOptions reads the options shared by the script steps and
+// reports all invalid ones at once.
+func readScriptOptions(def ScriptDefinition, cfg config.GlobalConfig) (scriptOptions, error) {
+	var opts scriptOptions
+	var errs []error
+
+	if def.Name == "" {
+		errs = append(errs, helper.InField("Name", errors.New("could not find step name")))
+	}
+
+	if def.Timeout != nil {
+		timeout, err := helper.ParseDuration(def.Timeout)
+		if err != nil {
+			errs = append(errs, helper.InField("Timeout", fmt.Errorf("invalid step timeout: %w", err)))
+		}
+		opts.timeout = timeout
+		slog.Info("Read step timeout", "timeout", opts.timeout)
+	}
+
+	retry, err := readRetry(def)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	opts.retry = retry
+
+	opts.env = readEnvironment(def, cfg)
+
+	return opts, errors.Join(errs...)
+}
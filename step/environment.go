@@ -0,0 +1,89 @@
+package step
+
+import (
+	"log/slog"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+
+	"executrix/constants"
+	"executrix/server/config"
+)
+
+// environment configures the environment variables and the working directory
+// of the child process of a script step.
+type environment struct {
+	vars       map[string]string // $(var) is substituted when executing the step
+	inherit    bool              // start with the environment of the server
+	workingDir string            // the working directory of the server if empty
+}
+
+// readEnvironment reads the optional "Env", "InheritEnv" and "WorkingDir"
+// options of a script step. Global vars are substituted right away.
+func readEnvironment(def ScriptDefinition, cfg config.GlobalConfig) environment {
+	env := environment{
+		vars:       map[string]string{},
+		inherit:    true,
+		workingDir: cfg.Substitute(def.WorkingDir),
+	}
+
+	for name, value := range def.Env {
+		env.vars[name] = cfg.Substitute(value)
+	}
+	if len(env.vars) > 0 {
+		slog.Info("Read step env", "vars", sortedKeys(env.vars))
+	}
+
+	if def.InheritEnv != nil {
+		env.inherit = *def.InheritEnv
+		slog.Info("Read step inherit env", "inherit", env.inherit)
+	}
+
+	if env.workingDir != "" {
+		slog.Info("Read step working dir", "dir", env.workingDir)
+	}
+
+	return env
+}
+
+// apply sets the environment and working directory of cmd. The variables of
+// the run are substituted, and the run metadata and the parameters are added
+// as EXECUTRIX_* variables, which take precedence over the variables of the
+// step.
+func (env environment) apply(cmd *exec.Cmd, run Run) {
+	cmd.Env = []string{}
+	if env.inherit {
+		// secrets are only passed on where they are referenced
+		cmd.Env = slices.DeleteFunc(os.Environ(), func(entry string) bool {
+			return strings.HasPrefix(entry, constants.SECRET_ENV_PREFIX)
+		})
+	}
+
+	// sorted, so that the environment is the same for every run
+	for _, name := range sortedKeys(env.vars) {
+		cmd.Env = append(cmd.Env, name+"="+substitute(env.vars[name], run.Vars))
+	}
+
+	for _, name := range sortedKeys(run.Env) {
+		cmd.Env = append(cmd.Env, name+"="+run.Env[name])
+	}
+
+	for _, name := range sortedKeys(run.Params) {
+		cmd.Env = append(cmd.Env, ParamEnv(name)+"="+run.Params[name])
+	}
+
+	if env.workingDir != "" {
+		cmd.Dir = substitute(env.workingDir, run.Vars)
+	}
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
@@ -3,17 +3,20 @@ package step
 import (
 	"errors"
 	"executrix/helper"
+	"executrix/output"
 	"executrix/server/config"
 	"log/slog"
+	"time"
 )
 
 type LinkStep struct {
-	Name  string // todo: this is public so it can be read in html template - should become decoupled
-	Link  string
-	state State
+	Name string // todo: this is public so it can be read in html template - should become decoupled
+	Link string
+
+	stepState
 }
 
-func (s LinkStep) Type() string {
+func (s *LinkStep) Type() string {
 	return "Link"
 }
 
@@ -21,12 +24,30 @@ func (s *LinkStep) ShowAs() string {
 	return s.Name
 }
 
-func (s *LinkStep) GetState() State {
-	return s.state
+func (s *LinkStep) Dependencies() []string {
+	return nil
+}
+
+func (s *LinkStep) ContinuesOnError() bool {
+	return false
 }
 
-func (s *LinkStep) SetState(state State) {
-	s.state = state
+func (s *LinkStep) IsDefault() bool {
+	return false
+}
+
+func (s *LinkStep) Timeout() time.Duration {
+	return 0
+}
+
+func (s *LinkStep) RetryPolicy() Retry {
+	return Retry{}
+}
+
+// Resolve returns the link with the values of the pipeline parameters
+// substituted.
+func (s *LinkStep) Resolve(params map[string]string) string {
+	return substitute(s.Link, params)
 }
 
 func (s *LinkStep) Kill() error {
@@ -34,28 +55,32 @@ func (s *LinkStep) Kill() error {
 	return nil
 }
 
-func (step *LinkStep) Execute(out *string) {
-	// nothing to do here (so far)
+// Execute does nothing, the link is opened by the user. The step succeeds, so
+// that it is not shown as waiting in the history of the run.
+func (step *LinkStep) Execute(out *output.Log, run Run) {
+	step.SetState(Success)
 }
 
-func ReadLinkType(s map[string]interface{}, cfg config.GlobalConfig) (*LinkStep, error) {
-	step := LinkStep{}
-
-	if val, ok := s["Name"].(string); !ok {
-		return nil, errors.New("could not find step name")
-	} else {
-		step.Name = val
-		slog.Info("Read step name", "s", step.Name)
+func ReadLinkType(def LinkDefinition, cfg config.GlobalConfig) (*LinkStep, error) {
+	step := LinkStep{
+		Name: def.Name,
+		Link: cfg.Substitute(def.Link),
 	}
+	slog.Info("Read step name", "s", step.Name)
+	slog.Info("Read link", "s", step.Link)
 
-	if val, ok := s["Link"].(string); !ok {
-		return nil, errors.New("could not find link")
-	} else {
-		step.Link = helper.ReplaceAll(val, cfg.GetVars())
-		slog.Info("Read link", "s", step.Link)
+	var errs []error
+	if def.Name == "" {
+		errs = append(errs, helper.InField("Name", errors.New("could not find step name")))
+	}
+	if def.Link == "" {
+		errs = append(errs, helper.InField("Link", errors.New("could not find link")))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
-	step.state = Waiting
+	step.SetState(Waiting)
 
 	return &step, nil
 }
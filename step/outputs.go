@@ -0,0 +1,67 @@
+package step
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+
+	"executrix/output"
+)
+
+// Steps publish output variables for later steps either by printing a line
+//
+//	::set-output name=value
+//
+// to stdout, or by writing "name=value" lines to the file named by the
+// environment variable EXECUTRIX_OUTPUT. Later steps reference them as
+// $(steps.<step>.<name>).
+const (
+	outputPrefix  = "::set-output "
+	outputFileEnv = "EXECUTRIX_OUTPUT"
+)
+
+var outputName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// OutputVar returns the name under which an output variable of a step is
+// substituted in later steps.
+func OutputVar(step string, name string) string {
+	return "steps." + step + "." + name
+}
+
+// publishLine publishes the output variable, if the line of stdout sets one.
+func publishLine(text string, out *output.Log, publish func(name, value string)) {
+	if assignment, ok := strings.CutPrefix(text, outputPrefix); ok {
+		publishAssignment(assignment, out, publish)
+	}
+}
+
+// publishFile publishes all output variables written to the output file.
+func publishFile(path string, out *output.Log, publish func(name, value string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := strings.TrimRight(scanner.Text(), "\r"); line != "" {
+			publishAssignment(line, out, publish)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func publishAssignment(assignment string, out *output.Log, publish func(name, value string)) {
+	name, value, ok := strings.Cut(assignment, "=")
+	if !ok || !outputName.MatchString(name) {
+		out.Append(output.System, "Ignoring invalid output variable: "+assignment)
+		return
+	}
+
+	if publish != nil {
+		publish(name, value)
+	}
+}
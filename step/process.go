@@ -0,0 +1,209 @@
+package step
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"executrix/helper"
+	"executrix/output"
+)
+
+var (
+	ErrTimedOut = errors.New("timed out")
+	ErrKilled   = errors.New("killed before the process was started")
+)
+
+// process runs the child process of a script step inside its own process
+// exit group, so that killing the step takes down the whole process tree.
+type process struct {
+	mu     sync.Mutex
+	group  *helper.ProcessExitGroup
+	leader *os.Process
+}
+
+// run starts cmd and streams its stdout and stderr line by line into out
+// until the process has terminated. Output variables set by the process are
+// passed to the publish function of run. If the timeout is exceeded, the
+// process is terminated and ErrTimedOut is returned.
+func (p *process) run(cmd *exec.Cmd, out *output.Log, run Run) error {
+	limits := run.Limits
+
+	g, err := helper.NewProcessExitGroup()
+	if err != nil {
+		return fmt.Errorf("could not create process exit group: %w", err)
+	}
+	defer g.Dispose()
+
+	g.Prepare(cmd)
+
+	outputFile, err := os.CreateTemp("", "executrix-output-*")
+	if err != nil {
+		return fmt.Errorf("could not create output file: %w", err)
+	}
+	outputFile.Close()
+	defer os.Remove(outputFile.Name())
+
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, outputFileEnv+"="+outputFile.Name())
+
+	outPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("could not get stdout pipe: %w", err)
+	}
+
+	errPipe, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("could not get stderr pipe: %w", err)
+	}
+
+	if aborted(run.Abort) {
+		return ErrKilled
+	}
+
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("could not start process: %w", err)
+	}
+
+	if err := g.AddProcess(cmd.Process); err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("could not add process to process exit group: %w", err)
+	}
+
+	p.mu.Lock()
+	p.group = &g
+	p.leader = cmd.Process
+	p.mu.Unlock()
+
+	defer p.release()
+
+	// a kill of the execution after the check above found no process to
+	// signal, the abort channel is closed before the steps are killed
+	if aborted(run.Abort) {
+		if err := p.kill(); err != nil {
+			slog.Error("Failed to kill process", "error", err)
+		}
+	}
+
+	exited := make(chan struct{})
+	defer close(exited)
+
+	var timedOut atomic.Bool
+	if limits.Timeout > 0 {
+		timer := time.AfterFunc(limits.Timeout, func() {
+			timedOut.Store(true)
+			out.Append(output.System, "Timed out after "+limits.Timeout.String()+", terminating")
+			p.terminate(limits.GracePeriod, exited)
+		})
+		defer timer.Stop()
+	}
+
+	waitgroup := &sync.WaitGroup{}
+	waitgroup.Add(2)
+
+	scan := func(r io.Reader, stream output.Stream) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			if stream == output.Stdout {
+				publishLine(scanner.Text(), out, run.Publish)
+			}
+			out.Append(stream, scanner.Text())
+		}
+		waitgroup.Done()
+	}
+
+	go scan(outPipe, output.Stdout)
+	go scan(errPipe, output.Stderr)
+
+	// all output has to be read before waiting for the process
+	waitgroup.Wait()
+
+	// the group must not be signalled once the process has been reaped
+	if err := g.AwaitExit(cmd.Process); err != nil {
+		slog.Warn("Failed to wait for process", "error", err)
+	}
+	p.release()
+
+	err = cmd.Wait()
+	if timedOut.Load() {
+		return fmt.Errorf("%w after %s", ErrTimedOut, limits.Timeout)
+	}
+
+	if err := publishFile(outputFile.Name(), out, run.Publish); err != nil {
+		out.Append(output.System, "Could not read output variables: "+err.Error())
+	}
+
+	return err
+}
+
+// aborted reports whether the abort channel of a run has been closed.
+func aborted(abort <-chan struct{}) bool {
+	select {
+	case <-abort:
+		return true
+	default:
+		return false
+	}
+}
+
+// release forgets the process, so that it is not signalled anymore.
+func (p *process) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.group = nil
+	p.leader = nil
+}
+
+// terminate interrupts the process and kills it, if it has not exited after
+// the grace period.
+func (p *process) terminate(grace time.Duration, exited <-chan struct{}) {
+	if err := p.interrupt(); err != nil {
+		slog.Warn("Failed to interrupt process", "error", err)
+	}
+
+	select {
+	case <-exited:
+	case <-time.After(grace):
+		slog.Warn("Process still running after grace period", "grace", grace)
+		if err := p.kill(); err != nil {
+			slog.Error("Failed to kill process", "error", err)
+		}
+	}
+}
+
+// interrupt asks the process and its children to exit, if running.
+func (p *process) interrupt() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.group == nil {
+		return nil
+	}
+
+	slog.Debug("Interrupting process exit group")
+	return p.group.Interrupt(p.leader)
+}
+
+// kill terminates the process and all of its children, if running.
+func (p *process) kill() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.group == nil {
+		return nil
+	}
+
+	slog.Debug("Terminating process exit group")
+	return p.group.Terminate()
+}
@@ -1,30 +1,34 @@
 package step
 
 import (
-	"bufio"
 	"errors"
 	"log/slog"
 	"os/exec"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"executrix/helper"
+	"executrix/output"
 	"executrix/server/config"
 )
 
 type PSStep struct {
-	Name       string // todo: this is public so it can be read in html template - should become decoupled
-	DependsOn  []string
-	Default    bool
-	scriptPath string
-	state      State
-	args       []string
-	cmd        *exec.Cmd
+	Name            string // todo: this is public so it can be read in html template - should become decoupled
+	DependsOn       []string
+	Default         bool
+	ContinueOnError bool // failing does not stop the pipeline or dependent steps
+	scriptPath      string
+	timeout         time.Duration
+	retry           Retry
+	env             environment
+	args            []string
+	proc            *process
+
+	stepState
 }
 
-func (s PSStep) Type() string {
+func (s *PSStep) Type() string {
 	return "PS"
 }
 
@@ -32,159 +36,112 @@ func (s *PSStep) ShowAs() string {
 	return s.Name
 }
 
-func (s *PSStep) GetState() State {
-	return s.state
+func (s *PSStep) Dependencies() []string {
+	return s.DependsOn
 }
 
-func (s *PSStep) SetState(state State) {
-	s.state = state
+func (s *PSStep) ContinuesOnError() bool {
+	return s.ContinueOnError
 }
 
-func (s *PSStep) Kill() error {
-	if s.cmd != nil {
-		slog.Info("Trying to kill PS step", "step", s.Name)
-		return s.cmd.Process.Kill()
-	}
-
-	return nil
+func (s *PSStep) IsDefault() bool {
+	return s.Default
 }
 
-func (step *PSStep) Execute(out *string) {
-	step.SetState(Running)
-
-	start := time.Now()
-
-	slog.Info("Excuting PS step", "step", step.Name, "script", step.scriptPath)
-	helper.AppendLine(out, "Excuting PS step: "+step.Name)
+func (s *PSStep) Timeout() time.Duration {
+	return s.timeout
+}
 
-	args := []string{"-nologo", "-noprofile", "-noninteractive", step.scriptPath}
-	args = append(args, step.args...)
-	helper.AppendLine(out, "Excution: powershell "+strings.Join(args, " "))
-	helper.AppendLine(out, "")
+func (s *PSStep) RetryPolicy() Retry {
+	return s.retry
+}
 
-	g, err := helper.NewProcessExitGroup()
-	if err != nil {
-		slog.Error("Error getting creating process exit group", "error", err)
-		helper.AppendLine(out, "Error getting creating process exit group: "+err.Error())
-		step.SetState(Failed)
-		return
-	}
-	defer g.Dispose()
+func (s *PSStep) Kill() error {
+	slog.Info("Trying to kill PS step", "step", s.Name)
+	return s.proc.kill()
+}
 
-	step.cmd = exec.Command("powershell", args...)
-	defer func() { step.cmd = nil }()
+func (step *PSStep) Execute(out *output.Log, run Run) {
+	step.SetState(Running)
 
-	outPipe, err := step.cmd.StdoutPipe()
-	if err != nil {
-		slog.Error("Error getting stdout pipe in PS step", "error", err)
-		helper.AppendLine(out, "Error getting stdout pipe in PS step: "+err.Error())
-		step.SetState(Failed)
-		return
-	}
+	start := time.Now()
 
-	errPipe, err := step.cmd.StderrPipe()
-	if err != nil {
-		slog.Error("Error getting stderr pipe in PS step", "error", err)
-		helper.AppendLine(out, "Error getting stderr pipe in PS step: "+err.Error())
-		step.SetState(Failed)
-		return
-	}
+	scriptPath := substitute(step.scriptPath, run.Vars)
 
-	waitgroup := &sync.WaitGroup{}
-	waitgroup.Add(2)
+	slog.Info("Excuting PS step", "step", step.Name, "script", out.Mask(scriptPath))
+	out.Append(output.System, "Excuting PS step: "+step.Name)
 
-	if err := step.cmd.Start(); err != nil {
-		slog.Error("Error starting PS step", "error", err)
-		helper.AppendLine(out, "Error starting PS step: "+err.Error())
-		step.SetState(Failed)
-		return
+	// with -File, the arguments are passed to the script as strings instead
+	// of being parsed as part of a command
+	args := []string{"-nologo", "-noprofile", "-noninteractive", "-File", scriptPath}
+	for _, arg := range step.args {
+		args = append(args, substitute(arg, run.Vars))
 	}
+	out.Append(output.System, "Excution: powershell "+strings.Join(args, " "))
 
-	if err := g.AddProcess(step.cmd.Process); err != nil {
-		slog.Error("Error adding process to process exit group", "error", err)
-		helper.AppendLine(out, "Error adding process to process exit group: "+err.Error())
-		step.SetState(Failed)
-		return
+	cmd := exec.Command("powershell", args...)
+	step.env.apply(cmd, run)
+	if cmd.Dir != "" {
+		out.Append(output.System, "Working directory: "+cmd.Dir)
 	}
+	out.Append(output.System, "")
 
-	go func() {
-		scanner := bufio.NewScanner(outPipe)
-		for scanner.Scan() {
-			helper.AppendLine(out, helper.CleanUpString(scanner.Text()))
-		}
-		waitgroup.Done()
-	}()
-
-	go func() {
-		scanner := bufio.NewScanner(errPipe)
-		for scanner.Scan() {
-			helper.AppendLine(out, helper.CleanUpString(scanner.Text()))
-		}
-		waitgroup.Done()
-	}()
-
-	if err := step.cmd.Wait(); err != nil {
-		slog.Error("Error waiting for PS step", "error", err)
-		helper.AppendLine(out, "Error waiting for PS step: "+err.Error())
+	if err := step.proc.run(cmd, out, run); err != nil {
+		slog.Error("Error executing PS step", "step", step.Name, "error", out.Mask(err.Error()))
+		out.Append(output.System, "Error executing PS step: "+err.Error())
 		step.SetState(Failed)
 		return
 	}
 
-	waitgroup.Wait()
-
 	slog.Info("Finished executing PS step", "step", step.Name)
-	helper.AppendLine(out, "")
-	helper.AppendLine(out, "")
-	helper.AppendLine(out, "Successfully finished PS step: "+step.Name)
-	helper.AppendLine(out, "Duration: "+strconv.FormatFloat(time.Since(start).Seconds(), 'f', -1, 64)+"secs")
+	out.Append(output.System, "")
+	out.Append(output.System, "")
+	out.Append(output.System, "Successfully finished PS step: "+step.Name)
+	out.Append(output.System, "Duration: "+strconv.FormatFloat(time.Since(start).Seconds(), 'f', -1, 64)+"secs")
 
 	step.SetState(Success)
 }
 
-func ReadPSType(s map[string]interface{}, cfg config.GlobalConfig) (*PSStep, error) {
-	step := PSStep{}
-	step.cmd = nil
-
-	if val, ok := s["Name"].(string); !ok {
-		return nil, errors.New("could not find step name")
-	} else {
-		step.Name = val
-		slog.Info("Read step name", "s", step.Name)
+func ReadPSType(def PSDefinition, cfg config.GlobalConfig) (*PSStep, error) {
+	step := PSStep{
+		Name:            def.Name,
+		DependsOn:       def.DependsOn,
+		Default:         def.Default,
+		ContinueOnError: def.ContinueOnError,
+		proc:            &process{},
 	}
+	slog.Info("Read step name", "s", step.Name)
+
+	opts, err := readScriptOptions(def.ScriptDefinition, cfg)
+	errs := []error{err}
+	step.timeout = opts.timeout
+	step.retry = opts.retry
+	step.env = opts.env
 
-	if val, ok := s["Default"].(bool); !ok {
-		step.Default = false
-	} else {
-		step.Default = val
-		slog.Info("Read step name", "s", step.Default)
+	if def.ScriptPath == "" {
+		errs = append(errs, helper.InField("ScriptPath", errors.New("could not find script path")))
 	}
+	step.scriptPath = cfg.Substitute(def.ScriptPath)
+	slog.Info("Read script path", "path", step.scriptPath)
 
-	if val, ok := s["ScriptPath"].(string); !ok {
-		return nil, errors.New("could not find script path")
-	} else {
-		step.scriptPath = helper.ReplaceAll(val, cfg.GetVars())
-		slog.Info("Read script path", "path", step.scriptPath)
+	if def.Arguments == nil {
+		errs = append(errs, helper.InField("Arguments", errors.New("could not find script args")))
+	}
+	for _, arg := range def.Arguments {
+		step.args = append(step.args, cfg.Substitute(arg))
 	}
+	slog.Info("Read script args", "args", step.args)
 
-	if val, ok := s["Arguments"].([]interface{}); !ok {
-		return nil, errors.New("could not find script args")
-	} else {
-		for _, v := range val {
-			step.args = append(step.args, helper.ReplaceAll(v.(string), cfg.GetVars()))
-		}
-		slog.Info("Read script args", "args", step.args)
+	if def.DependsOn == nil {
+		errs = append(errs, helper.InField("DependsOn", errors.New("could not find script dependencies")))
 	}
+	slog.Info("Read script dependencies", "dependencies", step.DependsOn)
 
-	if val, ok := s["DependsOn"].([]interface{}); !ok {
-		return nil, errors.New("could not find script dependencies")
-	} else {
-		for _, v := range val {
-			step.DependsOn = append(step.DependsOn, v.(string))
-		}
-		slog.Info("Read script dependencies", "dependencies", step.DependsOn)
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
-	step.state = Waiting
+	step.SetState(Waiting)
 
 	return &step, nil
 }
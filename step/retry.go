@@ -0,0 +1,52 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"executrix/helper"
+)
+
+// Retry configures how often a failed step is executed again.
+type Retry struct {
+	Retries int           // additional attempts after the first one failed
+	Delay   time.Duration // before the first retry
+	Backoff bool          // the delay doubles with every further retry
+}
+
+// DelayAfter returns how long to wait after the given failed attempt,
+// starting at 1.
+func (r Retry) DelayAfter(attempt int) time.Duration {
+	if !r.Backoff || attempt < 2 {
+		return r.Delay
+	}
+
+	return r.Delay * time.Duration(1<<min(attempt-1, 20))
+}
+
+// readRetry reads the optional "Retries", "RetryDelay" and "RetryBackoff"
+// options of a script step.
+func readRetry(def ScriptDefinition) (Retry, error) {
+	retry := Retry{
+		Retries: def.Retries,
+		Backoff: def.RetryBackoff,
+	}
+
+	if retry.Retries < 0 {
+		return Retry{}, helper.InField("Retries", errors.New("retries have to be a non-negative integer"))
+	}
+	slog.Info("Read step retries", "retries", retry.Retries, "backoff", retry.Backoff)
+
+	if def.RetryDelay != nil {
+		delay, err := helper.ParseDuration(def.RetryDelay)
+		if err != nil {
+			return Retry{}, helper.InField("RetryDelay", fmt.Errorf("invalid retry delay: %w", err))
+		}
+		retry.Delay = delay
+		slog.Info("Read step retry delay", "delay", retry.Delay)
+	}
+
+	return retry, nil
+}
@@ -0,0 +1,226 @@
+package step
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"executrix/helper"
+	"executrix/output"
+	"executrix/server/config"
+)
+
+const defaultInterpreter = "sh"
+
+type ShellStep struct {
+	Name            string // todo: this is public so it can be read in html template - should become decoupled
+	DependsOn       []string
+	Default         bool
+	ContinueOnError bool // failing does not stop the pipeline or dependent steps
+	interpreter     string
+	scriptPath      string
+	command         string
+	timeout         time.Duration
+	retry           Retry
+	env             environment
+	args            []string
+	proc            *process
+
+	stepState
+}
+
+func (s *ShellStep) Type() string {
+	return "Shell"
+}
+
+func (s *ShellStep) ShowAs() string {
+	return s.Name
+}
+
+func (s *ShellStep) Dependencies() []string {
+	return s.DependsOn
+}
+
+func (s *ShellStep) ContinuesOnError() bool {
+	return s.ContinueOnError
+}
+
+func (s *ShellStep) IsDefault() bool {
+	return s.Default
+}
+
+func (s *ShellStep) Timeout() time.Duration {
+	return s.timeout
+}
+
+func (s *ShellStep) RetryPolicy() Retry {
+	return s.retry
+}
+
+func (s *ShellStep) Kill() error {
+	slog.Info("Trying to kill shell step", "step", s.Name)
+	return s.proc.kill()
+}
+
+// commandLine returns the interpreter arguments: either the script path or,
+// for inline commands, "-c" followed by the command, then the step arguments.
+// The vars of the run are substituted in all of them, see substituteCommand
+// for the parameters in inline commands.
+func (s *ShellStep) commandLine(interpreter string, run Run) ([]string, error) {
+	var args []string
+	if s.command != "" {
+		command, err := s.substituteCommand(interpreter, run)
+		if err != nil {
+			return nil, err
+		}
+		args = []string{"-c", command}
+	} else {
+		args = []string{substitute(s.scriptPath, run.Vars)}
+	}
+
+	// the arguments are passed on as they are, they are not parsed by the
+	// interpreter
+	for _, arg := range s.args {
+		args = append(args, substitute(arg, run.Vars))
+	}
+
+	return args, nil
+}
+
+// substituteCommand substitutes the vars of the run in the inline command.
+// Parameters may be given by anyone who can trigger the pipeline, so they are
+// not put into the command, which is parsed by the interpreter. A reference
+// to a parameter becomes a reference to its environment variable instead,
+// see ParamEnv, which sh-compatible shells expand like any other variable.
+// Other interpreters have to read the environment variable themselves.
+func (s *ShellStep) substituteCommand(interpreter string, run Run) (string, error) {
+	var err error
+	vars := lookup(run.Vars)
+
+	command := helper.ReplaceFunc(s.command, func(ref string) (string, bool) {
+		name, fallback, hasFallback := strings.Cut(ref, ":-")
+		if value, ok := run.Params[name]; !ok || run.Vars[name] != value {
+			return vars(ref)
+		}
+
+		if !isPosixShell(interpreter) {
+			err = fmt.Errorf("parameter %q cannot be used in a command for %s, read the environment variable %s instead", name, interpreter, ParamEnv(name))
+			return "", false
+		}
+
+		if hasFallback {
+			return "${" + ParamEnv(name) + ":-" + fallback + "}", true
+		}
+		return "${" + ParamEnv(name) + "}", true
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return helper.Unescape(command), nil
+}
+
+// isPosixShell reports whether the interpreter is a shell which expands
+// ${NAME} and ${NAME:-fallback}.
+func isPosixShell(interpreter string) bool {
+	name := strings.TrimSuffix(filepath.Base(interpreter), ".exe")
+	return slices.Contains([]string{"sh", "bash", "dash", "ash", "ksh", "mksh", "zsh"}, name)
+}
+
+func (step *ShellStep) Execute(out *output.Log, run Run) {
+	step.SetState(Running)
+
+	start := time.Now()
+
+	interpreter := substitute(step.interpreter, run.Vars)
+
+	slog.Info("Executing shell step", "step", step.Name, "interpreter", out.Mask(interpreter))
+	out.Append(output.System, "Executing shell step: "+step.Name)
+
+	args, err := step.commandLine(interpreter, run)
+	if err != nil {
+		slog.Error("Error executing shell step", "step", step.Name, "error", err)
+		out.Append(output.System, "Error executing shell step: "+err.Error())
+		step.SetState(Failed)
+		return
+	}
+	out.Append(output.System, "Execution: "+interpreter+" "+strings.Join(args, " "))
+
+	cmd := exec.Command(interpreter, args...)
+	step.env.apply(cmd, run)
+	if cmd.Dir != "" {
+		out.Append(output.System, "Working directory: "+cmd.Dir)
+	}
+	out.Append(output.System, "")
+
+	if err := step.proc.run(cmd, out, run); err != nil {
+		slog.Error("Error executing shell step", "step", step.Name, "error", out.Mask(err.Error()))
+		out.Append(output.System, "Error executing shell step: "+err.Error())
+		step.SetState(Failed)
+		return
+	}
+
+	slog.Info("Finished executing shell step", "step", step.Name)
+	out.Append(output.System, "")
+	out.Append(output.System, "")
+	out.Append(output.System, "Successfully finished shell step: "+step.Name)
+	out.Append(output.System, "Duration: "+strconv.FormatFloat(time.Since(start).Seconds(), 'f', -1, 64)+"secs")
+
+	step.SetState(Success)
+}
+
+func ReadShellType(def ShellDefinition, cfg config.GlobalConfig) (*ShellStep, error) {
+	step := ShellStep{
+		Name:            def.Name,
+		DependsOn:       def.DependsOn,
+		Default:         def.Default,
+		ContinueOnError: def.ContinueOnError,
+		interpreter:     defaultInterpreter,
+		proc:            &process{},
+	}
+	slog.Info("Read step name", "s", step.Name)
+
+	opts, err := readScriptOptions(def.ScriptDefinition, cfg)
+	errs := []error{err}
+	step.timeout = opts.timeout
+	step.retry = opts.retry
+	step.env = opts.env
+
+	if def.Interpreter != "" {
+		step.interpreter = cfg.Substitute(def.Interpreter)
+		slog.Info("Read interpreter", "interpreter", step.interpreter)
+	}
+
+	switch {
+	case def.ScriptPath != "" && def.Command != "":
+		errs = append(errs, helper.InField("Command", errors.New("script path and command must not both be set")))
+	case def.ScriptPath != "":
+		step.scriptPath = cfg.Substitute(def.ScriptPath)
+		slog.Info("Read script path", "path", step.scriptPath)
+	case def.Command != "":
+		step.command = cfg.Substitute(def.Command)
+		slog.Info("Read command", "command", step.command)
+	default:
+		errs = append(errs, errors.New("could not find script path or command"))
+	}
+
+	for _, arg := range def.Arguments {
+		step.args = append(step.args, cfg.Substitute(arg))
+	}
+	slog.Info("Read script args", "args", step.args)
+	slog.Info("Read script dependencies", "dependencies", step.DependsOn)
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	step.SetState(Waiting)
+
+	return &step, nil
+}
@@ -0,0 +1,84 @@
+package step
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSubstituteCommand(t *testing.T) {
+	run := Run{
+		Vars: map[string]string{
+			"executrix.date": "2024-05-14",
+			"branch":         "main",
+			"out":            "/tmp/out",
+			"empty":          "",
+		},
+		Params: map[string]string{"branch": "main"},
+	}
+
+	tests := []struct {
+		name        string
+		interpreter string
+		command     string
+		want        string
+	}{
+		{"command substitution", "sh", "echo $(date)", "echo $(date)"},
+		{"escaped command substitution", "sh", "echo $$(date)", "echo $(date)"},
+		{"built-in var", "sh", "echo $(executrix.date)", "echo 2024-05-14"},
+		{"command substitution with arguments", "sh", "echo $(date +%F)", "echo $(date +%F)"},
+		{"var", "sh", "cp x $(out)", "cp x /tmp/out"},
+		{"fallback", "sh", "echo $(empty:-none) $(unset:-none)", "echo none none"},
+		{"parameter", "sh", "git checkout $(branch)", "git checkout ${EXECUTRIX_PARAM_BRANCH}"},
+		{"parameter with fallback", "/bin/bash", `echo "$(branch:-main)"`, `echo "${EXECUTRIX_PARAM_BRANCH:-main}"`},
+		{"other interpreter", "python3", "print('$(out)')", "print('/tmp/out')"},
+	}
+
+	for _, test := range tests {
+		s := ShellStep{command: test.command}
+		got, err := s.substituteCommand(test.interpreter, run)
+		if err != nil {
+			t.Errorf("%s: substituteCommand(%q): %v", test.name, test.command, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: substituteCommand(%q) = %q, want %q", test.name, test.command, got, test.want)
+		}
+	}
+}
+
+func TestSubstituteCommandParameterErrors(t *testing.T) {
+	run := Run{
+		Vars:   map[string]string{"branch": "main"},
+		Params: map[string]string{"branch": "main"},
+	}
+
+	for _, interpreter := range []string{"python3", "pwsh", "node"} {
+		s := ShellStep{command: "print('$(branch)')"}
+		_, err := s.substituteCommand(interpreter, run)
+		if err == nil {
+			t.Errorf("substituteCommand for %s succeeded, want error", interpreter)
+			continue
+		}
+		if want := "EXECUTRIX_PARAM_BRANCH"; !strings.Contains(err.Error(), want) {
+			t.Errorf("substituteCommand for %s = %q, want error containing %q", interpreter, err, want)
+		}
+	}
+}
+
+func TestParamEnv(t *testing.T) {
+	tests := []struct {
+		param string
+		want  string
+	}{
+		{"branch", "EXECUTRIX_PARAM_BRANCH"},
+		{"buildNumber", "EXECUTRIX_PARAM_BUILDNUMBER"},
+		{"target-os", "EXECUTRIX_PARAM_TARGET_OS"},
+		{"v1.2", "EXECUTRIX_PARAM_V1_2"},
+	}
+
+	for _, test := range tests {
+		if got := ParamEnv(test.param); got != test.want {
+			t.Errorf("ParamEnv(%q) = %q, want %q", test.param, got, test.want)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package step
 
 import (
-	"errors"
-	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	"executrix/server/config"
+	"executrix/helper"
+	"executrix/output"
 )
 
 type State int
@@ -15,31 +17,107 @@ const (
 	Failed
 	Success
 	Semi
+	Skipped // not executed because the pipeline was stopped
+	Blocked // not executed because a dependency did not succeed
 )
 
+func (s State) String() string {
+	switch s {
+	case Waiting:
+		return "Waiting"
+	case Running:
+		return "Running"
+	case Failed:
+		return "Failed"
+	case Success:
+		return "Success"
+	case Semi:
+		return "Semi"
+	case Skipped:
+		return "Skipped"
+	case Blocked:
+		return "Blocked"
+	default:
+		return "Unknown"
+	}
+}
+
+// stepState holds the state of a step. It is set by the goroutine executing
+// the step and read concurrently by the executor and the handlers.
+type stepState struct {
+	state atomic.Int32
+}
+
+func (s *stepState) GetState() State {
+	return State(s.state.Load())
+}
+
+func (s *stepState) SetState(state State) {
+	s.state.Store(int32(state))
+}
+
+// Limits restrict the execution of a step.
+type Limits struct {
+	Timeout     time.Duration // no timeout if 0
+	GracePeriod time.Duration // between interrupting and killing a timed out step
+}
+
+// Run holds everything a step needs to know about the run executing it.
+type Run struct {
+	Limits  Limits
+	Vars    map[string]string        // substituted for $(name) when executing the step
+	Params  map[string]string        // the parameters among Vars, see ParamEnv
+	Env     map[string]string        // additional environment variables describing the run
+	Publish func(name, value string) // receives the output variables of the step
+	Abort   <-chan struct{}          // closed when the execution is killed
+}
+
+// substitute replaces the vars of the run in an option of a step. The
+// fallback of a reference like $(name:-fallback) is used if the var is not
+// defined or empty. Escaped references like $$(name) are passed on as
+// $(name).
+func substitute(s string, vars map[string]string) string {
+	return helper.Unescape(helper.ReplaceFunc(s, lookup(vars)))
+}
+
+func lookup(vars map[string]string) func(ref string) (string, bool) {
+	return func(ref string) (string, bool) {
+		name, fallback, hasFallback := strings.Cut(ref, ":-")
+		if value, ok := vars[name]; ok && (value != "" || !hasFallback) {
+			return value, true
+		}
+
+		return fallback, hasFallback
+	}
+}
+
+// ParamEnv returns the name of the environment variable which holds the
+// value of a parameter in script steps, e.g. EXECUTRIX_PARAM_BRANCH for
+// "branch". Characters not allowed in shell variable names are replaced by
+// underscores.
+func ParamEnv(param string) string {
+	name := []byte(strings.ToUpper(param))
+	for i, c := range name {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+			name[i] = '_'
+		}
+	}
+
+	return paramEnvPrefix + string(name)
+}
+
+const paramEnvPrefix = "EXECUTRIX_PARAM_"
+
 type IStep interface {
 	ShowAs() string
 	Type() string
+	Dependencies() []string
+	ContinuesOnError() bool
+	IsDefault() bool // selected when running the default steps
+	Timeout() time.Duration
+	RetryPolicy() Retry
 	GetState() State
 	SetState(b State)
-	Execute(out *string)
+	Execute(out *output.Log, run Run)
 	Kill() error
 }
-
-func StepFromJSON(s map[string]interface{}, cfg config.GlobalConfig) (IStep, error) {
-	val, ok := s["Type"].(string)
-	if !ok {
-		return nil, errors.New("could not find step type")
-	}
-
-	slog.Info("Read step type", "type", val)
-	switch val {
-	case "PS":
-		return ReadPSType(s, cfg)
-	case "Link":
-		return ReadLinkType(s, cfg)
-	default:
-		slog.Error("Unknown step type", "type", val)
-		return nil, errors.New("unknown step type")
-	}
-}
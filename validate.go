@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"executrix/constants"
+	"executrix/helper"
+	"executrix/pipeline"
+	"executrix/server/config"
+)
+
+const validateUsage = `usage: executrix validate [<file|dir>...]
+
+Checks pipeline files and prints every problem found. Directories are
+checked file by file, without arguments the pipeline directory is checked.
+The exit code is 1 if there are problems, warnings do not count.`
+
+// validate runs the validate subcommand and returns the exit code.
+func validate(args []string) int {
+	// problems are printed, the log would only repeat them
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError + 1})))
+
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			fmt.Println(validateUsage)
+			return 0
+		}
+	}
+
+	configBaseDir, err := os.UserConfigDir()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to determine user default config location:", err)
+		return 2
+	}
+	configDir := filepath.Join(configBaseDir, constants.CONFIG_DIR_NAME)
+
+	if len(args) == 0 {
+		args = []string{filepath.Join(configDir, constants.PIPELINE_DIR_NAME)}
+	}
+
+	cfg, secrets, err := validationContext(configDir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	files, err := pipelineFiles(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	problems := 0
+	warnings := 0
+	invalid := 0
+	for _, file := range files {
+		loadErrs := pipeline.ValidateFile(file, cfg, secrets)
+
+		fileProblems := 0
+		for _, loadErr := range loadErrs {
+			fmt.Println(loadErr.Error())
+			if loadErr.Warning {
+				warnings++
+			} else {
+				fileProblems++
+			}
+		}
+
+		problems += fileProblems
+		if fileProblems > 0 {
+			invalid++
+		}
+	}
+
+	if problems > 0 {
+		fmt.Printf("%d problem(s) in %d of %d file(s), %d warning(s)\n", problems, invalid, len(files), warnings)
+		return 1
+	}
+
+	fmt.Printf("%d file(s) valid, %d warning(s)\n", len(files), warnings)
+	return 0
+}
+
+// validationContext reads the global vars and the names of the secrets,
+// which are defined for all pipelines. The config is not created if it is
+// missing.
+func validationContext(configDir string) (config.GlobalConfig, []string, error) {
+	cfg := config.NewGlobalConfig(configDir)
+
+	cfgPath := filepath.Join(configDir, constants.GLOBAL_CONFIG_FILE)
+	exists, err := helper.Exists(cfgPath)
+	if err != nil {
+		return config.GlobalConfig{}, nil, err
+	}
+	if exists {
+		if cfg, err = config.GlobalConfigFromJson(cfgPath); err != nil {
+			return config.GlobalConfig{}, nil, fmt.Errorf("%s: %w", cfgPath, err)
+		}
+	}
+
+	secrets, err := config.SecretsFromJson(filepath.Join(configDir, constants.SECRETS_FILE))
+	if err != nil {
+		return config.GlobalConfig{}, nil, fmt.Errorf("failed to load secrets: %w", err)
+	}
+
+	return cfg, secrets.Names(), nil
+}
+
+// pipelineFiles returns the given files and the files in the given
+// directories.
+func pipelineFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		found, err := helper.FindAllFiles(arg)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, found...)
+	}
+
+	return files, nil
+}